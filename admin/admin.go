@@ -0,0 +1,221 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"strings"
+
+	"github.com/irisco88/teltonika-device/command"
+	"github.com/irisco88/teltonika-device/logging"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/session"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	ErrMissingToken = errors.New("admin api token is required")
+
+	errUnauthorized     = errors.New("unauthorized")
+	errNotFound         = errors.New("not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errNotConnected     = errors.New("device is not connected")
+)
+
+const (
+	SessionsPath = "/api/v1/sessions"
+	CommandsPath = "/api/v1/commands"
+	// DebugVarsPath serves expvar, it includes command line of the process
+	DebugVarsPath = "/debug/vars"
+)
+
+// Server is the gateway state operated by admin api
+type Server interface {
+	Sessions() *session.Registry
+	Commands() *command.Log
+}
+
+// Handler serves admin api, every request needs the bearer token:
+//
+//	GET    /api/v1/sessions                  connected devices
+//	GET    /api/v1/sessions/{imei}           session and last decoded packet of a device
+//	DELETE /api/v1/sessions/{imei}           force disconnect a device
+//	PUT    /api/v1/sessions/{imei}/debug     enable debug logging of a device, DELETE disables it
+//	GET    /api/v1/sessions/{imei}/commands  command log of a device
+//	POST   /api/v1/sessions/{imei}/commands  queue a command, body is {"command":"getinfo"}
+//	GET    /api/v1/commands                  command log of all devices
+//	GET    /api/v1/logging                   log level and devices with debug logging, PUT changes level
+//	PUT    /api/v1/logging/debug/{imei}      same as /api/v1/sessions/{imei}/debug for devices not connected
+//	GET    /debug/vars                       expvar counters
+type Handler struct {
+	server Server
+	logs   *logging.Controller
+	token  []byte
+}
+
+// NewHandler creates admin api of server, debug logging is not available when logs is nil
+func NewHandler(server Server, logs *logging.Controller, token string) (*Handler, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	return &Handler{
+		server: server,
+		logs:   logs,
+		token:  []byte(token),
+	}, nil
+}
+
+// SessionDetail is a session with its last decoded packet
+type SessionDetail struct {
+	Session    session.Info      `json:"session"`
+	LastPacket []json.RawMessage `json:"last_packet"`
+}
+
+// QueueRequest is the body of queue command requests
+type QueueRequest struct {
+	Command string `json:"command"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeHTTPError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	switch {
+	case req.URL.Path == logging.PathPrefix || strings.HasPrefix(req.URL.Path, logging.PathPrefix+"/"):
+		if h.logs == nil {
+			writeHTTPError(w, http.StatusNotFound, errNotFound)
+			return
+		}
+		h.logs.ServeHTTP(w, req)
+	case req.URL.Path == DebugVarsPath:
+		expvar.Handler().ServeHTTP(w, req)
+	case req.URL.Path == CommandsPath:
+		if req.Method != http.MethodGet {
+			writeHTTPError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.server.Commands().List(""))
+	case req.URL.Path == SessionsPath:
+		if req.Method != http.MethodGet {
+			writeHTTPError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.server.Sessions().List())
+	case strings.HasPrefix(req.URL.Path, SessionsPath+"/"):
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, SessionsPath), "/"), "/")
+		imei, resource := parts[0], strings.Join(parts[1:], "/")
+		switch resource {
+		case "":
+			h.serveSession(w, req, imei)
+		case "debug":
+			h.serveDebug(w, req, imei)
+		case "commands":
+			h.serveCommands(w, req, imei)
+		default:
+			writeHTTPError(w, http.StatusNotFound, errNotFound)
+		}
+	default:
+		writeHTTPError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+func (h *Handler) authorized(req *http.Request) bool {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), h.token) == 1
+}
+
+func (h *Handler) serveSession(w http.ResponseWriter, req *http.Request, imei string) {
+	devSess, found := h.server.Sessions().Get(imei)
+	if !found {
+		writeHTTPError(w, http.StatusNotFound, errNotConnected)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		detail := &SessionDetail{
+			Session:    devSess.Info(),
+			LastPacket: make([]json.RawMessage, 0),
+		}
+		for _, point := range devSess.LastPacket() {
+			data, err := protojson.Marshal(point)
+			if err != nil {
+				writeHTTPError(w, http.StatusInternalServerError, err)
+				return
+			}
+			detail.LastPacket = append(detail.LastPacket, data)
+		}
+		writeJSON(w, http.StatusOK, detail)
+	case http.MethodDelete:
+		if err := devSess.Close(); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveDebug(w http.ResponseWriter, req *http.Request, imei string) {
+	if h.logs == nil {
+		writeHTTPError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	switch req.Method {
+	case http.MethodPut:
+		h.logs.SetDebug(imei, true)
+	case http.MethodDelete:
+		h.logs.SetDebug(imei, false)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"imei": imei, "debug": h.logs.Debug(imei)})
+}
+
+func (h *Handler) serveCommands(w http.ResponseWriter, req *http.Request, imei string) {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.server.Commands().List(imei))
+	case http.MethodPost:
+		if err := parser.ValidateIMEI(imei, parser.IMEIRelaxed); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		var queueReq QueueRequest
+		if err := json.NewDecoder(req.Body).Decode(&queueReq); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(queueReq.Command) > parser.MaxCommandLength {
+			writeHTTPError(w, http.StatusBadRequest, parser.ErrCommandTooLong)
+			return
+		}
+		cmd, err := h.server.Commands().Queue(imei, queueReq.Command)
+		if errors.Is(err, command.ErrTooManyQueued) {
+			writeHTTPError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, cmd)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
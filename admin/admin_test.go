@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/command"
+	"github.com/irisco88/teltonika-device/logging"
+	"github.com/irisco88/teltonika-device/session"
+	"gotest.tools/v3/assert"
+)
+
+const testToken = "secret"
+
+type testServer struct {
+	sessions *session.Registry
+	commands *command.Log
+}
+
+func (s *testServer) Sessions() *session.Registry { return s.sessions }
+func (s *testServer) Commands() *command.Log      { return s.commands }
+
+func newTestHandler(t *testing.T) (*Handler, *testServer, *logging.Controller, net.Conn) {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	srv := &testServer{
+		sessions: session.NewRegistry(),
+		commands: command.NewLog(0),
+	}
+	devSess := session.New("356307042441013", serverConn)
+	devSess.SetLastPacket([]*pb.AVLData{{Imei: "356307042441013", Timestamp: "2023-09-27T07:33:20Z"}})
+	srv.sessions.Register(devSess, session.DuplicateCloseOld)
+
+	opts := logging.DefaultOptions
+	_, controller, err := logging.New(opts)
+	assert.NilError(t, err)
+	handler, err := NewHandler(srv, controller, testToken)
+	assert.NilError(t, err)
+	return handler, srv, controller, clientConn
+}
+
+func serve(handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler(&testServer{}, nil, "")
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestHandler_Auth(t *testing.T) {
+	handler, _, _, _ := newTestHandler(t)
+	tests := map[string]struct {
+		path  string
+		token string
+		code  int
+	}{
+		"missing":          {path: SessionsPath, token: "", code: http.StatusUnauthorized},
+		"wrong":            {path: SessionsPath, token: "wrong", code: http.StatusUnauthorized},
+		"valid":            {path: SessionsPath, token: testToken, code: http.StatusOK},
+		"debug vars":       {path: DebugVarsPath, token: "", code: http.StatusUnauthorized},
+		"valid debug vars": {path: DebugVarsPath, token: testToken, code: http.StatusOK},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := serve(handler, http.MethodGet, tc.path, tc.token, "")
+			assert.Equal(t, recorder.Code, tc.code)
+		})
+	}
+}
+
+func TestHandler_Sessions(t *testing.T) {
+	handler, srv, _, clientConn := newTestHandler(t)
+
+	recorder := serve(handler, http.MethodGet, SessionsPath, testToken, "")
+	var infos []session.Info
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &infos))
+	assert.Equal(t, len(infos), 1)
+
+	recorder = serve(handler, http.MethodGet, SessionsPath+"/356307042441013", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	var detail struct {
+		Session    session.Info     `json:"session"`
+		LastPacket []map[string]any `json:"last_packet"`
+	}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &detail))
+	assert.Equal(t, detail.Session.IMEI, "356307042441013")
+	assert.Equal(t, len(detail.LastPacket), 1)
+	assert.Equal(t, detail.LastPacket[0]["imei"], "356307042441013")
+
+	recorder = serve(handler, http.MethodGet, SessionsPath+"/352094087982671", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	// disconnect closes device connection
+	recorder = serve(handler, http.MethodDelete, SessionsPath+"/356307042441013", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusNoContent)
+	_, err := clientConn.Read(make([]byte, 1))
+	assert.Assert(t, err != nil)
+	assert.Equal(t, srv.sessions.Len(), 1)
+}
+
+func TestHandler_Debug(t *testing.T) {
+	handler, _, controller, _ := newTestHandler(t)
+
+	recorder := serve(handler, http.MethodPut, SessionsPath+"/356307042441013/debug", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, controller.Debug("356307042441013"))
+
+	recorder = serve(handler, http.MethodDelete, SessionsPath+"/356307042441013/debug", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, !controller.Debug("356307042441013"))
+
+	recorder = serve(handler, http.MethodGet, SessionsPath+"/356307042441013/debug", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusMethodNotAllowed)
+}
+
+func TestHandler_Logging(t *testing.T) {
+	handler, _, controller, _ := newTestHandler(t)
+
+	recorder := serve(handler, http.MethodPut, logging.PathPrefix, "", `{"level":"debug"}`)
+	assert.Equal(t, recorder.Code, http.StatusUnauthorized)
+
+	recorder = serve(handler, http.MethodPut, logging.PathPrefix, testToken, `{"level":"warn"}`)
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	var state logging.State
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &state))
+	assert.Equal(t, state.Level, "warn")
+
+	recorder = serve(handler, http.MethodPut, logging.PathPrefix+"/debug/352094087982671", testToken, "")
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, controller.Debug("352094087982671"))
+}
+
+func TestHandler_Commands(t *testing.T) {
+	handler, srv, _, _ := newTestHandler(t)
+	tests := map[string]struct {
+		imei string
+		body string
+		code int
+	}{
+		"queued":       {imei: "356307042441013", body: `{"command":"getinfo"}`, code: http.StatusAccepted},
+		"offline":      {imei: "352094087982671", body: `{"command":"getver"}`, code: http.StatusAccepted},
+		"empty":        {imei: "356307042441013", body: `{"command":""}`, code: http.StatusBadRequest},
+		"invalid body": {imei: "356307042441013", body: `getinfo`, code: http.StatusBadRequest},
+		"invalid imei": {imei: "abc", body: `{"command":"getinfo"}`, code: http.StatusBadRequest},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := serve(handler, http.MethodPost, SessionsPath+"/"+tc.imei+"/commands", testToken, tc.body)
+			assert.Equal(t, recorder.Code, tc.code)
+		})
+	}
+
+	recorder := serve(handler, http.MethodGet, SessionsPath+"/356307042441013/commands", testToken, "")
+	var commands []command.Command
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &commands))
+	assert.Equal(t, len(commands), 1)
+	assert.Equal(t, commands[0].Text, "getinfo")
+	assert.Equal(t, commands[0].Status, command.StatusQueued)
+
+	recorder = serve(handler, http.MethodGet, CommandsPath, testToken, "")
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &commands))
+	assert.Equal(t, len(commands), 2)
+	assert.Equal(t, len(srv.commands.List("352094087982671")), 1)
+
+	for i := 1; i < command.MaxQueuedPerDevice; i++ {
+		recorder = serve(handler, http.MethodPost, SessionsPath+"/356307042441013/commands", testToken, `{"command":"getver"}`)
+		assert.Equal(t, recorder.Code, http.StatusAccepted)
+	}
+	recorder = serve(handler, http.MethodPost, SessionsPath+"/356307042441013/commands", testToken, `{"command":"getver"}`)
+	assert.Equal(t, recorder.Code, http.StatusTooManyRequests)
+}
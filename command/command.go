@@ -0,0 +1,189 @@
+package command
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEmptyCommand  = errors.New("command is empty")
+	ErrTooManyQueued = errors.New("too many queued commands for device")
+)
+
+// Status of a queued command
+type Status string
+
+const (
+	// StatusQueued commands are sent after next data packet of the device
+	StatusQueued   Status = "queued"
+	StatusSent     Status = "sent"
+	StatusAnswered Status = "answered"
+	StatusFailed   Status = "failed"
+)
+
+const (
+	// DefaultLogSize is the number of commands kept by Log
+	DefaultLogSize = 1000
+	// MaxQueuedPerDevice limits commands of a device waiting to be sent
+	MaxQueuedPerDevice = 20
+)
+
+// Command is a GPRS command sent to a device with codec 12
+type Command struct {
+	ID         uint64    `json:"id"`
+	IMEI       string    `json:"imei"`
+	Text       string    `json:"command"`
+	Status     Status    `json:"status"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	QueuedAt   time.Time `json:"queued_at"`
+	SentAt     time.Time `json:"sent_at,omitempty"`
+	AnsweredAt time.Time `json:"answered_at,omitempty"`
+	// session is the id of the device session the command was sent on
+	session uint64
+}
+
+// Log queues commands per device and keeps the latest commands with their responses,
+// oldest finished commands are dropped when it is full
+type Log struct {
+	mu       sync.Mutex
+	size     int
+	nextID   uint64
+	commands []*Command
+}
+
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+	return &Log{size: size}
+}
+
+// Queue adds a command for imei and returns its snapshot
+func (l *Log) Queue(imei, text string) (Command, error) {
+	if text == "" {
+		return Command{}, ErrEmptyCommand
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	queued := 0
+	for _, cmd := range l.commands {
+		if cmd.IMEI == imei && cmd.Status == StatusQueued {
+			queued++
+		}
+	}
+	if queued >= MaxQueuedPerDevice {
+		return Command{}, ErrTooManyQueued
+	}
+	l.nextID++
+	cmd := &Command{
+		ID:       l.nextID,
+		IMEI:     imei,
+		Text:     text,
+		Status:   StatusQueued,
+		QueuedAt: time.Now(),
+	}
+	l.commands = append(l.commands, cmd)
+	l.trim()
+	return *cmd, nil
+}
+
+// Claim marks queued commands of imei as sent on session and returns them in queue order,
+// a command is claimed only once even when the device has more than one session
+func (l *Log) Claim(imei string, session uint64) []Command {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var claimed []Command
+	for _, cmd := range l.commands {
+		if cmd.IMEI == imei && cmd.Status == StatusQueued {
+			cmd.Status = StatusSent
+			cmd.SentAt = time.Now()
+			cmd.session = session
+			claimed = append(claimed, *cmd)
+		}
+	}
+	return claimed
+}
+
+// MarkFailed marks command id as failed with err
+func (l *Log) MarkFailed(id uint64, err error) {
+	l.update(id, func(cmd *Command) {
+		cmd.Status = StatusFailed
+		cmd.Error = err.Error()
+	})
+}
+
+// Answer stores response of the oldest command sent on session, devices answer commands in order.
+// It returns the answered command, false when no command of session waits for a response
+func (l *Log) Answer(session uint64, response string) (Command, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, cmd := range l.commands {
+		if cmd.session == session && cmd.Status == StatusSent {
+			cmd.Status = StatusAnswered
+			cmd.Response = response
+			cmd.AnsweredAt = time.Now()
+			return *cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// FailSent marks commands sent on session and waiting for a response as failed, used when the session ends
+func (l *Log) FailSent(session uint64, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, cmd := range l.commands {
+		if cmd.session == session && cmd.Status == StatusSent {
+			cmd.Status = StatusFailed
+			cmd.Error = err.Error()
+		}
+	}
+}
+
+// List returns commands of imei, or of all devices when imei is empty, newest first
+func (l *Log) List(imei string) []Command {
+	l.mu.Lock()
+	commands := make([]Command, 0, len(l.commands))
+	for _, cmd := range l.commands {
+		if imei == "" || cmd.IMEI == imei {
+			commands = append(commands, *cmd)
+		}
+	}
+	l.mu.Unlock()
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].ID > commands[j].ID
+	})
+	return commands
+}
+
+func (l *Log) update(id uint64, fn func(cmd *Command)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, cmd := range l.commands {
+		if cmd.ID == id {
+			fn(cmd)
+			return
+		}
+	}
+}
+
+// trim drops oldest finished commands above size, queued and sent commands are kept,
+// queued commands are bounded by MaxQueuedPerDevice
+func (l *Log) trim() {
+	excess := len(l.commands) - l.size
+	if excess <= 0 {
+		return
+	}
+	kept := l.commands[:0]
+	for _, cmd := range l.commands {
+		if excess > 0 && (cmd.Status == StatusAnswered || cmd.Status == StatusFailed) {
+			excess--
+			continue
+		}
+		kept = append(kept, cmd)
+	}
+	l.commands = kept
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLog(t *testing.T) {
+	log := NewLog(0)
+	_, err := log.Queue("356307042441013", "")
+	assert.ErrorIs(t, err, ErrEmptyCommand)
+
+	first, err := log.Queue("356307042441013", "getinfo")
+	assert.NilError(t, err)
+	second, err := log.Queue("356307042441013", "getver")
+	assert.NilError(t, err)
+	other, err := log.Queue("352094087982671", "getgps")
+	assert.NilError(t, err)
+	assert.Equal(t, first.Status, StatusQueued)
+
+	// commands are claimed once, a second session of the same device gets nothing
+	const session, otherSession = 1, 2
+	claimed := log.Claim("356307042441013", session)
+	assert.Equal(t, len(claimed), 2)
+	assert.Equal(t, claimed[0].ID, first.ID)
+	assert.Equal(t, claimed[1].ID, second.ID)
+	assert.Equal(t, claimed[0].Status, StatusSent)
+	assert.Equal(t, len(log.Claim("356307042441013", otherSession)), 0)
+
+	// responses answer commands sent on the same session in order
+	_, answered := log.Answer(otherSession, "RTC:2023/9/27")
+	assert.Assert(t, !answered)
+	answer, answered := log.Answer(session, "RTC:2023/9/27")
+	assert.Assert(t, answered)
+	assert.Equal(t, answer.ID, first.ID)
+	// an ending session fails only its own commands
+	log.FailSent(otherSession, errors.New("other session closed"))
+	log.FailSent(session, errors.New("device disconnected"))
+	_, answered = log.Answer(session, "Ver:03.27.07")
+	assert.Assert(t, !answered)
+	log.MarkFailed(other.ID, errors.New("write failed"))
+
+	commands := log.List("356307042441013")
+	assert.Equal(t, len(commands), 2)
+	assert.Equal(t, commands[0].Status, StatusFailed)
+	assert.Equal(t, commands[0].Error, "device disconnected")
+	assert.Equal(t, commands[1].Status, StatusAnswered)
+	assert.Equal(t, commands[1].Response, "RTC:2023/9/27")
+	assert.Assert(t, !commands[1].AnsweredAt.IsZero())
+
+	all := log.List("")
+	assert.Equal(t, len(all), 3)
+	assert.Equal(t, all[0].ID, other.ID)
+	assert.Equal(t, all[0].Error, "write failed")
+}
+
+func TestLog_Trim(t *testing.T) {
+	log := NewLog(2)
+	log.Queue("356307042441013", "getver")
+	log.Claim("356307042441013", 1)
+	log.Answer(1, "Ver:03.27.07")
+	first, _ := log.Queue("356307042441013", "getinfo")
+
+	// finished commands are dropped before queued ones
+	third, _ := log.Queue("356307042441013", "getgps")
+	commands := log.List("")
+	assert.Equal(t, len(commands), 2)
+	assert.Equal(t, commands[0].ID, third.ID)
+	assert.Equal(t, commands[1].ID, first.ID)
+
+	// queued commands are never dropped
+	log.Queue("356307042441013", "cpureset")
+	assert.Equal(t, len(log.List("")), 3)
+}
+
+func TestLog_MaxQueued(t *testing.T) {
+	log := NewLog(0)
+	for i := 0; i < MaxQueuedPerDevice; i++ {
+		_, err := log.Queue("356307042441013", "getinfo")
+		assert.NilError(t, err)
+	}
+	_, err := log.Queue("356307042441013", "getinfo")
+	assert.ErrorIs(t, err, ErrTooManyQueued)
+	_, err = log.Queue("352094087982671", "getinfo")
+	assert.NilError(t, err)
+
+	// claimed commands no longer count as queued
+	log.Claim("356307042441013", 1)
+	_, err = log.Queue("356307042441013", "getinfo")
+	assert.NilError(t, err)
+}
@@ -0,0 +1,37 @@
+package avldb
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/db/clickhouse"
+	"github.com/irisco88/teltonika-device/db/postgres"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported avldb url scheme, use clickhouse:// or postgres://")
+
+// Connect connects to avldb backend selected by databaseURL scheme
+func Connect(databaseURL string, ioColumns *db.IOColumnMapping) (db.AVLDBConn, error) {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "clickhouse", "tcp":
+		conn, err := clickhouse.ConnectAvlDB(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		conn.IOColumns = ioColumns
+		return conn, nil
+	case "postgres", "postgresql":
+		conn, err := postgres.ConnectAvlDB(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		conn.IOColumns = ioColumns
+		return conn, nil
+	}
+	return nil, ErrUnsupportedScheme
+}
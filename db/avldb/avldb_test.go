@@ -0,0 +1,14 @@
+package avldb
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestConnectUnsupportedScheme(t *testing.T) {
+	for _, databaseURL := range []string{"mysql://localhost/avl", "localhost:9000"} {
+		_, err := Connect(databaseURL, nil)
+		assert.ErrorIs(t, err, ErrUnsupportedScheme)
+	}
+}
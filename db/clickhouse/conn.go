@@ -7,26 +7,29 @@ import (
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
-	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
 )
 
-//go:generate mockgen -source=$GOFILE -destination=mock_db/conn.go -package=$GOPACKAG
-type AVLDBConn interface {
-	GetConn() driver.Conn
-	SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error
-	SaveRawData(ctx context.Context, imei, payload string) error
-}
-
-var _ AVLDBConn = &AVLDataBase{}
+var _ db.AVLDBConn = &AVLDataBase{}
 
 type AVLDataBase struct {
 	ClickhouseConn driver.Conn
+	// IOColumns maps io elements to typed avlpoints columns, DefaultIOColumns is used when nil
+	IOColumns *db.IOColumnMapping
 }
 
 func (adb *AVLDataBase) GetConn() driver.Conn {
 	return adb.ClickhouseConn
 }
 
+func (adb *AVLDataBase) Ping(ctx context.Context) error {
+	return adb.ClickhouseConn.Ping(ctx)
+}
+
+func (adb *AVLDataBase) Close() error {
+	return adb.ClickhouseConn.Close()
+}
+
 func ConnectAvlDB(databaseURL string) (*AVLDataBase, error) {
 	opts, err := clickhouse.ParseDSN(databaseURL)
 	if err != nil {
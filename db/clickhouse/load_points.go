@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+)
+
+const selectAvlPointsQuery = `
+	SELECT imei, toString(timestamp), priority, longitude, latitude, altitude, angle, satellites, speed, event_id, io_elements
+	FROM %s FINAL
+	WHERE imei = ? AND toString(timestamp) IN (?)
+	ORDER BY timestamp;
+`
+
+const selectTrackQuery = `
+	SELECT imei, toString(timestamp), priority, longitude, latitude, altitude, angle, satellites, speed, event_id, io_elements
+	FROM avlpoints FINAL
+	WHERE imei = ? AND timestamp >= ? AND timestamp < ?
+	ORDER BY timestamp
+	LIMIT ?;
+`
+
+const createTableLikeQuery = `
+	CREATE TABLE IF NOT EXISTS %s AS %s;
+`
+
+// LoadAvlPointsAt loads stored points of imei from table at the given timestamps
+func (adb *AVLDataBase) LoadAvlPointsAt(ctx context.Context, table, imei string, timestamps []string) ([]*pb.AVLData, error) {
+	if !db.ValidTableName(table) {
+		return nil, db.ErrInvalidTableName
+	}
+	if len(timestamps) == 0 {
+		return nil, nil
+	}
+	rows, err := adb.GetConn().Query(ctx, fmt.Sprintf(selectAvlPointsQuery, table), imei, timestamps)
+	if err != nil {
+		return nil, err
+	}
+	return scanAvlPoints(rows)
+}
+
+// LoadTrack loads at most limit points of imei stored in [from, to) ordered by timestamp
+func (adb *AVLDataBase) LoadTrack(ctx context.Context, imei string, from, to time.Time, limit int) ([]*pb.AVLData, error) {
+	rows, err := adb.GetConn().Query(ctx, selectTrackQuery, imei, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAvlPoints(rows)
+}
+
+func scanAvlPoints(rows driver.Rows) ([]*pb.AVLData, error) {
+	defer rows.Close()
+	var points []*pb.AVLData
+	for rows.Next() {
+		var (
+			point      = &pb.AVLData{Gps: &pb.GPS{}}
+			priority   string
+			altitude   int16
+			angle      int16
+			satellites uint8
+			speed      int16
+			eventID    uint16
+			elements   map[string]float64
+		)
+		if e := rows.Scan(
+			&point.Imei,
+			&point.Timestamp,
+			&priority,
+			&point.Gps.Longitude,
+			&point.Gps.Latitude,
+			&altitude,
+			&angle,
+			&satellites,
+			&speed,
+			&eventID,
+			&elements,
+		); e != nil {
+			return nil, fmt.Errorf("scan avl point failed:%v", e)
+		}
+		point.Priority = pb.PacketPriority(pb.PacketPriority_value[priority])
+		point.Gps.Altitude = int32(altitude)
+		point.Gps.Angle = int32(angle)
+		point.Gps.Satellites = int32(satellites)
+		point.Gps.Speed = int32(speed)
+		point.EventId = uint32(eventID)
+		point.IoElements = elementsFromMap(elements)
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// CreateTableLike creates table with the same structure and engine as source if it does not exist
+func (adb *AVLDataBase) CreateTableLike(ctx context.Context, table, source string) error {
+	if !db.ValidTableName(table) || !db.ValidTableName(source) {
+		return db.ErrInvalidTableName
+	}
+	return adb.GetConn().Exec(ctx, fmt.Sprintf(createTableLikeQuery, table, source))
+}
+
+// elementsFromMap converts stored io_elements map to io elements sorted by name
+func elementsFromMap(elements map[string]float64) []*pb.IOElement {
+	result := make([]*pb.IOElement, 0, len(elements))
+	for name, value := range elements {
+		result = append(result, &pb.IOElement{
+			ElementName:  name,
+			ElementValue: value,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ElementName < result[j].ElementName
+	})
+	return result
+}
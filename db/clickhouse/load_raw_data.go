@@ -0,0 +1,63 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/irisco88/teltonika-device/db"
+)
+
+const selectRawDataQuery = `
+	SELECT imei, received_at, remote_addr, codec_id, record_count, crc_ok, parse_error, payload
+	FROM rawdatas
+	WHERE imei IN (?) AND received_at >= ? AND received_at < ?
+	ORDER BY imei, received_at;
+`
+
+const countRawDataQuery = `
+	SELECT count()
+	FROM rawdatas
+	WHERE imei IN (?) AND received_at >= ? AND received_at < ?;
+`
+
+// CountRawData counts archived raw frames matching filter
+func (adb *AVLDataBase) CountRawData(ctx context.Context, filter *db.RawFrameFilter) (uint64, error) {
+	var count uint64
+	row := adb.GetConn().QueryRow(ctx, countRawDataQuery, filter.IMEIs, filter.From, filter.To)
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// LoadRawData streams archived raw frames matching filter to fn, ordered by imei and receive time
+func (adb *AVLDataBase) LoadRawData(ctx context.Context, filter *db.RawFrameFilter, fn func(frame *db.RawFrame) error) error {
+	rows, err := adb.GetConn().Query(ctx, selectRawDataQuery, filter.IMEIs, filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			frame   db.RawFrame
+			payload string
+		)
+		if e := rows.Scan(
+			&frame.IMEI,
+			&frame.ReceivedAt,
+			&frame.RemoteAddr,
+			&frame.CodecID,
+			&frame.RecordCount,
+			&frame.CRCValid,
+			&frame.ParseError,
+			&payload,
+		); e != nil {
+			return fmt.Errorf("scan raw data failed:%v", e)
+		}
+		frame.Payload = []byte(payload)
+		if e := fn(&frame); e != nil {
+			return e
+		}
+	}
+	return rows.Err()
+}
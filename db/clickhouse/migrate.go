@@ -0,0 +1,158 @@
+package clickhouse
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/irisco88/teltonika-device/db"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const createMigrationsTableQuery = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+	    version    UInt32,
+	    name       String,
+	    applied    Bool,
+	    changed_at DateTime64(3)
+	)
+	ENGINE = ReplacingMergeTree(changed_at)
+	ORDER BY version;
+`
+
+const selectMigrationsQuery = `
+	SELECT version, argMax(applied, changed_at), max(changed_at)
+	FROM schema_migrations
+	GROUP BY version;
+`
+
+const insertMigrationQuery = `
+	INSERT INTO schema_migrations(version, name, applied, changed_at)
+	VALUES (?,?,?,?);
+`
+
+// LoadMigrations reads embedded clickhouse migrations sorted by version
+func LoadMigrations() ([]*db.Migration, error) {
+	return db.LoadMigrations(migrationFiles, "migrations")
+}
+
+// splitStatements splits a migration file into statements, clickhouse executes one statement per query
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// MigrationStatuses returns state of every embedded migration
+func (adb *AVLDataBase) MigrationStatuses(ctx context.Context) ([]*db.MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if e := adb.GetConn().Exec(ctx, createMigrationsTableQuery); e != nil {
+		return nil, fmt.Errorf("create migrations table failed:%v", e)
+	}
+	rows, err := adb.GetConn().Query(ctx, selectMigrationsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[uint32]*db.MigrationStatus)
+	for rows.Next() {
+		status := &db.MigrationStatus{}
+		var version uint32
+		if e := rows.Scan(&version, &status.Applied, &status.ChangedAt); e != nil {
+			return nil, fmt.Errorf("scan migration failed:%v", e)
+		}
+		applied[version] = status
+	}
+	if e := rows.Err(); e != nil {
+		return nil, e
+	}
+	statuses := make([]*db.MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status, found := applied[migration.Version]
+		if !found {
+			status = &db.MigrationStatus{}
+		}
+		status.Migration = migration
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies at most steps pending migrations in version order, zero steps applies all
+func (adb *AVLDataBase) MigrateUp(ctx context.Context, steps int) ([]*db.Migration, error) {
+	statuses, err := adb.MigrationStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []*db.Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if e := adb.runMigration(ctx, status.Migration, status.Up, true); e != nil {
+			return done, e
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts at most steps applied migrations in reverse version order, zero steps reverts all
+func (adb *AVLDataBase) MigrateDown(ctx context.Context, steps int) ([]*db.Migration, error) {
+	statuses, err := adb.MigrationStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []*db.Migration
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if e := adb.runMigration(ctx, status.Migration, status.Down, false); e != nil {
+			return done, e
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+func (adb *AVLDataBase) runMigration(ctx context.Context, migration *db.Migration, script string, applied bool) error {
+	for _, statement := range splitStatements(script) {
+		if e := adb.GetConn().Exec(ctx, statement); e != nil {
+			return fmt.Errorf("migration %04d_%s failed:%v", migration.Version, migration.Name, e)
+		}
+	}
+	return adb.GetConn().Exec(ctx, insertMigrationQuery, migration.Version, migration.Name, applied, time.Now())
+}
@@ -0,0 +1,34 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.NilError(t, err)
+	assert.Assert(t, len(migrations) > 0)
+	for i, migration := range migrations {
+		assert.Equal(t, migration.Version, uint32(i+1))
+		assert.Assert(t, len(splitStatements(migration.Up)) > 0)
+		assert.Assert(t, len(splitStatements(migration.Down)) > 0)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`
+-- comment line
+CREATE TABLE a
+(
+    id UInt32
+) ENGINE = Memory;
+DROP TABLE b;
+SELECT 1`)
+	assert.DeepEqual(t, statements, []string{
+		"CREATE TABLE a\n(\n    id UInt32\n) ENGINE = Memory;",
+		"DROP TABLE b;",
+		"SELECT 1",
+	})
+}
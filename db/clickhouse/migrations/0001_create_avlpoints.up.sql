@@ -0,0 +1,22 @@
+-- decoded avl records, one row per record
+-- ReplacingMergeTree keeps the latest inserted row per (imei, timestamp), so reprocessed rows replace old ones
+CREATE TABLE IF NOT EXISTS avlpoints
+(
+    imei           String,
+    timestamp      DateTime('Asia/Tehran'),
+    priority       LowCardinality(String),
+    longitude      Float64,
+    latitude       Float64,
+    altitude       Int16,
+    angle          Int16,
+    satellites     UInt8,
+    speed          Int16,
+    event_id       UInt16,
+    io_elements    Map(String, Float64),
+    parser_version LowCardinality(String),
+    inserted_at    DateTime64(3) DEFAULT now64(3)
+)
+ENGINE = ReplacingMergeTree(inserted_at)
+PARTITION BY toYYYYMM(timestamp)
+ORDER BY (imei, timestamp)
+TTL timestamp + INTERVAL 2 YEAR;
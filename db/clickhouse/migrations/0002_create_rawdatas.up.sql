@@ -0,0 +1,16 @@
+-- raw frames exactly as received from devices, used for replay and reprocessing
+CREATE TABLE IF NOT EXISTS rawdatas
+(
+    imei         String,
+    received_at  DateTime64(3),
+    remote_addr  String,
+    codec_id     UInt8,
+    record_count UInt8,
+    crc_ok       Bool,
+    parse_error  String,
+    payload      String CODEC(ZSTD(3))
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(received_at)
+ORDER BY (imei, received_at)
+TTL toDateTime(received_at) + INTERVAL 1 YEAR;
@@ -0,0 +1,10 @@
+ALTER TABLE avlpoints
+    DROP COLUMN IF EXISTS ignition,
+    DROP COLUMN IF EXISTS vehicle_speed,
+    DROP COLUMN IF EXISTS engine_rpm,
+    DROP COLUMN IF EXISTS fuel_level,
+    DROP COLUMN IF EXISTS coolant_temperature,
+    DROP COLUMN IF EXISTS external_voltage,
+    DROP COLUMN IF EXISTS battery_voltage,
+    DROP COLUMN IF EXISTS odometer,
+    DROP COLUMN IF EXISTS io_by_id;
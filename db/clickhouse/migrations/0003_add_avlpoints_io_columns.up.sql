@@ -0,0 +1,11 @@
+-- well-known signals as typed columns, unknown io elements keyed by numeric io id
+ALTER TABLE avlpoints
+    ADD COLUMN IF NOT EXISTS ignition Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS vehicle_speed Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS engine_rpm Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS fuel_level Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS coolant_temperature Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS external_voltage Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS battery_voltage Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS odometer Nullable(Float64),
+    ADD COLUMN IF NOT EXISTS io_by_id Map(UInt16, Float64);
@@ -0,0 +1,2 @@
+-- columns are part of tables created by 0001 and 0002, reverting this migration keeps them
+SELECT 1;
@@ -0,0 +1,13 @@
+-- tables created before migrations existed lack columns written by inserts,
+-- CREATE TABLE IF NOT EXISTS of 0001 and 0002 leaves them unchanged
+ALTER TABLE avlpoints
+    ADD COLUMN IF NOT EXISTS parser_version LowCardinality(String),
+    ADD COLUMN IF NOT EXISTS inserted_at DateTime64(3) DEFAULT now64(3);
+
+ALTER TABLE rawdatas
+    ADD COLUMN IF NOT EXISTS received_at DateTime64(3),
+    ADD COLUMN IF NOT EXISTS remote_addr String,
+    ADD COLUMN IF NOT EXISTS codec_id UInt8,
+    ADD COLUMN IF NOT EXISTS record_count UInt8,
+    ADD COLUMN IF NOT EXISTS crc_ok Bool,
+    ADD COLUMN IF NOT EXISTS parse_error String;
@@ -2,21 +2,38 @@ package clickhouse
 
 import (
 	"context"
+	"fmt"
 	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"strings"
 )
 
 const insertAvlPointQuery = `
 	INSERT INTO 
-	    avlpoints(imei, timestamp, priority, longitude, latitude, altitude, angle, satellites, speed,event_id, io_elements)
-	VALUES (?,?,?,?,?,?,?,?,?,?,?);
+	    %s(imei, timestamp, priority, longitude, latitude, altitude, angle, satellites, speed,event_id, io_elements, parser_version, io_by_id, %s)
+	VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,%s);
 `
 
+var (
+	ioColumnNames        = strings.Join(db.IOColumns, ", ")
+	ioColumnPlaceholders = strings.TrimSuffix(strings.Repeat("?,", len(db.IOColumns)), ",")
+)
+
 // SaveAvlPoints saves avl points to clickhouse
 func (adb *AVLDataBase) SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error {
+	return adb.SaveAvlPointsToTable(ctx, db.AvlPointsTable, points)
+}
+
+// SaveAvlPointsToTable saves avl points to the given table which must have avlpoints structure
+func (adb *AVLDataBase) SaveAvlPointsToTable(ctx context.Context, table string, points []*pb.AVLData) error {
 	//logger, _ := zap.NewDevelopment()
 	//defer logger.Sync()
 
-	batch, err := adb.ClickhouseConn.PrepareBatch(ctx, insertAvlPointQuery)
+	if !db.ValidTableName(table) {
+		return db.ErrInvalidTableName
+	}
+	batch, err := adb.ClickhouseConn.PrepareBatch(ctx, fmt.Sprintf(insertAvlPointQuery, table, ioColumnNames, ioColumnPlaceholders))
 	if err != nil {
 		//logger.Info("savePoints&&&&&&&&&&&&&&&&&&&&&&&&&&&&",
 		//	zap.Any("2:", err),
@@ -39,7 +56,8 @@ func (adb *AVLDataBase) SaveAvlPoints(ctx context.Context, points []*pb.AVLData)
 			//	zap.Any("4:", elementMap),
 			//)
 		}
-		err := batch.Append(
+		typedValues, unknownElements := adb.ioColumnMapping().Split(point.IoElements)
+		values := []any{
 			point.GetImei(),
 			//time.UnixMilli(int64(point.GetTimestamp())),
 			point.GetTimestamp(),
@@ -52,7 +70,13 @@ func (adb *AVLDataBase) SaveAvlPoints(ctx context.Context, points []*pb.AVLData)
 			int16(gps.GetSpeed()),
 			uint16(point.GetEventId()),
 			elementMap,
-		)
+			parser.Version,
+			unknownElements,
+		}
+		for _, value := range typedValues {
+			values = append(values, value)
+		}
+		err := batch.Append(values...)
 		//logger.Info("savePoints&&&&&&&&&&&&&&&&&&&&&&&&&&&&",
 		//	zap.Any("5:", err),
 		//)
@@ -68,3 +92,10 @@ func (adb *AVLDataBase) SaveAvlPoints(ctx context.Context, points []*pb.AVLData)
 	//)
 	return batch.Send()
 }
+
+func (adb *AVLDataBase) ioColumnMapping() *db.IOColumnMapping {
+	if adb.IOColumns == nil {
+		return db.DefaultIOColumnMapping
+	}
+	return adb.IOColumns
+}
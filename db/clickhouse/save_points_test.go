@@ -3,12 +3,13 @@ package clickhouse
 import (
 	"context"
 	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
 	"gotest.tools/v3/assert"
 	"os"
 	"testing"
 )
 
-func NewConnTest(t *testing.T) AVLDBConn {
+func NewConnTest(t *testing.T) db.AVLDBConn {
 	avlDB, err := ConnectAvlDB(os.Getenv("AVLDB_CLICKHOUSE"))
 	assert.NilError(t, err)
 	return avlDB
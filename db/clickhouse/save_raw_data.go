@@ -2,21 +2,33 @@ package clickhouse
 
 import (
 	"context"
+
+	"github.com/irisco88/teltonika-device/db"
 )
 
 const insertRawDataQuery = `
-	INSERT INTO rawdatas (imei, payload)
-VALUES (?,?);
-
+	INSERT INTO
+	    rawdatas(imei, received_at, remote_addr, codec_id, record_count, crc_ok, parse_error, payload)
+	VALUES (?,?,?,?,?,?,?,?);
 `
 
-// SaveRawData saves raw data to clickhouse
-func (adb *AVLDataBase) SaveRawData(ctx context.Context, imei, payload string) error {
+// SaveRawData saves raw frame to clickhouse
+func (adb *AVLDataBase) SaveRawData(ctx context.Context, frame *db.RawFrame) error {
 	batch, err := adb.GetConn().PrepareBatch(ctx, insertRawDataQuery)
 	if err != nil {
 		return err
 	}
-	if e := batch.Append(imei, payload); e != nil {
+	if e := batch.Append(
+		frame.IMEI,
+		frame.ReceivedAt,
+		frame.RemoteAddr,
+		frame.CodecID,
+		frame.RecordCount,
+		frame.CRCValid,
+		frame.ParseError,
+		// payload is kept as binary string, not hex, to keep the archive compact
+		string(frame.Payload),
+	); e != nil {
 		return e
 	}
 	return batch.Send()
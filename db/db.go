@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+)
+
+var (
+	ErrInvalidTableName = errors.New("invalid table name")
+)
+
+// AvlPointsTable is the live table decoded points are written to
+const AvlPointsTable = "avlpoints"
+
+// PointSink receives decoded points, every AVLDBConn is a PointSink
+type PointSink interface {
+	SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error
+	Close() error
+}
+
+//go:generate mockgen -source=$GOFILE -destination=mock_db/conn.go -package=mock_db
+type AVLDBConn interface {
+	SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error
+	SaveAvlPointsToTable(ctx context.Context, table string, points []*pb.AVLData) error
+	SaveRawData(ctx context.Context, frame *RawFrame) error
+	CountRawData(ctx context.Context, filter *RawFrameFilter) (uint64, error)
+	LoadRawData(ctx context.Context, filter *RawFrameFilter, fn func(frame *RawFrame) error) error
+	LoadAvlPointsAt(ctx context.Context, table, imei string, timestamps []string) ([]*pb.AVLData, error)
+	LoadTrack(ctx context.Context, imei string, from, to time.Time, limit int) ([]*pb.AVLData, error)
+	CreateTableLike(ctx context.Context, table, source string) error
+	MigrationStatuses(ctx context.Context) ([]*MigrationStatus, error)
+	MigrateUp(ctx context.Context, steps int) ([]*Migration, error)
+	MigrateDown(ctx context.Context, steps int) ([]*Migration, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// RawFrame is a single frame exactly as it was received from a device
+type RawFrame struct {
+	IMEI        string
+	ReceivedAt  time.Time
+	RemoteAddr  string
+	CodecID     uint8
+	RecordCount uint8
+	CRCValid    bool
+	ParseError  string
+	Payload     []byte
+}
+
+// RawFrameFilter selects archived raw frames by imei and receive time
+type RawFrameFilter struct {
+	IMEIs []string
+	From  time.Time
+	To    time.Time
+}
@@ -0,0 +1,191 @@
+package filesink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// recordWriter encodes records to an underlying file, Close flushes encoder without closing the file
+type recordWriter interface {
+	Write(record *Record) error
+	// Buffered returns size of encoded data held in memory and not yet written to the file
+	Buffered() int64
+	Close() error
+}
+
+type ndjsonWriter struct {
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	gz := gzip.NewWriter(w)
+	return &ndjsonWriter{
+		gzip:    gz,
+		encoder: json.NewEncoder(gz),
+	}
+}
+
+func (nw *ndjsonWriter) Write(record *Record) error {
+	return nw.encoder.Encode(record)
+}
+
+// Buffered is zero, gzip only holds a small compression window in memory
+func (nw *ndjsonWriter) Buffered() int64 {
+	return 0
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.gzip.Close()
+}
+
+// parquetRecord is the parquet schema of Record
+type parquetRecord struct {
+	IMEI       string             `parquet:"name=imei, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Timestamp  int64              `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Priority   string             `parquet:"name=priority, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Longitude  float64            `parquet:"name=longitude, type=DOUBLE"`
+	Latitude   float64            `parquet:"name=latitude, type=DOUBLE"`
+	Altitude   int32              `parquet:"name=altitude, type=INT32"`
+	Angle      int32              `parquet:"name=angle, type=INT32"`
+	Satellites int32              `parquet:"name=satellites, type=INT32"`
+	Speed      int32              `parquet:"name=speed, type=INT32"`
+	EventID    int64              `parquet:"name=event_id, type=INT64"`
+	IOElements map[string]float64 `parquet:"name=io_elements, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=DOUBLE"`
+}
+
+type parquetWriter struct {
+	writer *writer.ParquetWriter
+}
+
+// maxRowGroupSize limits memory a parquet file holds until a row group is written
+const maxRowGroupSize = 8 * 1024 * 1024
+
+func newParquetWriter(w io.Writer, maxFileSize int64) (*parquetWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRecord), 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+	pw.RowGroupSize = maxRowGroupSize
+	if maxFileSize < maxRowGroupSize {
+		pw.RowGroupSize = maxFileSize
+	}
+	return &parquetWriter{writer: pw}, nil
+}
+
+func (pw *parquetWriter) Write(record *Record) error {
+	return pw.writer.Write(&parquetRecord{
+		IMEI:       record.IMEI,
+		Timestamp:  record.Timestamp.UnixMilli(),
+		Priority:   record.Priority,
+		Longitude:  record.Longitude,
+		Latitude:   record.Latitude,
+		Altitude:   record.Altitude,
+		Angle:      record.Angle,
+		Satellites: record.Satellites,
+		Speed:      record.Speed,
+		EventID:    int64(record.EventID),
+		IOElements: record.IOElements,
+	})
+}
+
+// Buffered returns size of the row group being built, it is written to the file when RowGroupSize is reached
+func (pw *parquetWriter) Buffered() int64 {
+	return pw.writer.Size + pw.writer.ObjsSize
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.writer.WriteStop()
+}
+
+// countingWriter counts bytes written to file, used by size rotation
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.count += int64(n)
+	return n, err
+}
+
+// partFile is a partition file being written under a temporary name
+type partFile struct {
+	path     string
+	tmpPath  string
+	file     *os.File
+	buffer   *bufio.Writer
+	counter  *countingWriter
+	writer   recordWriter
+	openedAt time.Time
+	// writtenAt is the time of last write, least recently written files are finalized first
+	writtenAt time.Time
+}
+
+func openPartFile(path, format string, maxSize int64, openedAt time.Time) (*partFile, error) {
+	tmpPath := filepath.Join(filepath.Dir(path), tmpPrefix+filepath.Base(path)+tmpSuffix)
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	pf := &partFile{
+		path:      path,
+		tmpPath:   tmpPath,
+		file:      file,
+		buffer:    bufio.NewWriter(file),
+		openedAt:  openedAt,
+		writtenAt: openedAt,
+	}
+	pf.counter = &countingWriter{w: pf.buffer}
+	if format == FormatParquet {
+		pf.writer, err = newParquetWriter(pf.counter, maxSize)
+	} else {
+		pf.writer = newNDJSONWriter(pf.counter)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return pf, nil
+}
+
+func (pf *partFile) write(record *Record) error {
+	pf.writtenAt = time.Now()
+	return pf.writer.Write(record)
+}
+
+// size includes data the encoder holds in memory, so files rotate before MaxFileSize is exceeded on disk
+func (pf *partFile) size() int64 {
+	return pf.counter.count + pf.writer.Buffered()
+}
+
+// finalize flushes and syncs the file, then atomically renames it to its final name
+func (pf *partFile) finalize() error {
+	if err := pf.writer.Close(); err != nil {
+		pf.file.Close()
+		return err
+	}
+	if err := pf.buffer.Flush(); err != nil {
+		pf.file.Close()
+		return err
+	}
+	if err := pf.file.Sync(); err != nil {
+		pf.file.Close()
+		return err
+	}
+	if err := pf.file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(pf.tmpPath, pf.path)
+}
@@ -0,0 +1,379 @@
+package filesink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidFormat = errors.New("sink format must be ndjson or parquet")
+	ErrMissingDir    = errors.New("sink directory is required")
+	ErrSinkClosed    = errors.New("sink is closed")
+	ErrQueueFull     = errors.New("sink queue is full")
+)
+
+const (
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+
+	// DefaultMaxFileSize rotates a partition file after this many bytes are written,
+	// data buffered in memory by the encoder is counted too
+	DefaultMaxFileSize = 64 * 1024 * 1024
+	// DefaultMaxFileAge rotates a partition file after it has been open this long
+	DefaultMaxFileAge = time.Hour
+	// DefaultMaxOpenFiles limits partition files open at the same time,
+	// least recently written file is finalized to open a new one
+	DefaultMaxOpenFiles = 256
+	// DefaultQueueSize is the number of point batches waiting to be written
+	DefaultQueueSize = 1024
+
+	tmpPrefix = "."
+	tmpSuffix = ".tmp"
+)
+
+var _ db.PointSink = &Sink{}
+
+type Options struct {
+	// Dir is the root directory, files are written to Dir/date=YYYY-MM-DD/imei=IMEI/
+	Dir    string
+	Format string
+	// MaxFileSize in bytes, zero uses DefaultMaxFileSize
+	MaxFileSize int64
+	// MaxFileAge zero uses DefaultMaxFileAge
+	MaxFileAge time.Duration
+	// MaxOpenFiles zero uses DefaultMaxOpenFiles
+	MaxOpenFiles int
+	// QueueSize zero uses DefaultQueueSize
+	QueueSize int
+}
+
+func (o *Options) Validate() error {
+	switch {
+	case o.Dir == "":
+		return ErrMissingDir
+	case o.Format != FormatNDJSON && o.Format != FormatParquet:
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// Record is a flat avl point row written to files
+type Record struct {
+	IMEI       string             `json:"imei"`
+	Timestamp  time.Time          `json:"timestamp"`
+	Priority   string             `json:"priority"`
+	Longitude  float64            `json:"longitude"`
+	Latitude   float64            `json:"latitude"`
+	Altitude   int32              `json:"altitude"`
+	Angle      int32              `json:"angle"`
+	Satellites int32              `json:"satellites"`
+	Speed      int32              `json:"speed"`
+	EventID    uint32             `json:"event_id"`
+	IOElements map[string]float64 `json:"io_elements"`
+}
+
+func NewRecord(point *pb.AVLData) (*Record, error) {
+	timestamp, err := parser.ParseTimestamp(point.GetTimestamp())
+	if err != nil {
+		return nil, err
+	}
+	gps := point.GetGps()
+	elements := make(map[string]float64, len(point.GetIoElements()))
+	for _, element := range point.GetIoElements() {
+		elements[element.GetElementName()] = element.GetElementValue()
+	}
+	return &Record{
+		IMEI:       point.GetImei(),
+		Timestamp:  timestamp,
+		Priority:   point.GetPriority().String(),
+		Longitude:  gps.GetLongitude(),
+		Latitude:   gps.GetLatitude(),
+		Altitude:   gps.GetAltitude(),
+		Angle:      gps.GetAngle(),
+		Satellites: gps.GetSatellites(),
+		Speed:      gps.GetSpeed(),
+		EventID:    point.GetEventId(),
+		IOElements: elements,
+	}, nil
+}
+
+type partition struct {
+	date string
+	imei string
+}
+
+// batch is a group of records written together, written is closed after records are written
+type batch struct {
+	records []*Record
+	written chan struct{}
+}
+
+// Sink writes points to rotating files partitioned by date and imei.
+// Points are queued and written by a single goroutine, so writes never block callers.
+// Files are written with a hidden temporary name and renamed when finalized,
+// so readers never see partially written files.
+type Sink struct {
+	opts Options
+	log  *zap.Logger
+	// files is only used by the write goroutine
+	files map[partition]*partFile
+	queue chan batch
+	done  chan struct{}
+	// closeErr is the error of finalizing files on close
+	closeErr error
+
+	mu     sync.Mutex
+	closed bool
+	// writeErr is the error of last write, cleared by a successful write
+	writeErr error
+}
+
+func NewSink(opts Options, logger *zap.Logger) (*Sink, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.MaxFileAge <= 0 {
+		opts.MaxFileAge = DefaultMaxFileAge
+	}
+	if opts.MaxOpenFiles <= 0 {
+		opts.MaxOpenFiles = DefaultMaxOpenFiles
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	sink := &Sink{
+		opts:  opts,
+		log:   logger,
+		files: make(map[partition]*partFile),
+		queue: make(chan batch, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go sink.run()
+	return sink, nil
+}
+
+// SaveAvlPoints queues points to be appended to their partition files,
+// ErrQueueFull is returned instead of waiting when writes fall behind
+func (s *Sink) SaveAvlPoints(_ context.Context, points []*pb.AVLData) error {
+	records := make([]*Record, 0, len(points))
+	for _, point := range points {
+		record, err := NewRecord(point)
+		if err != nil {
+			s.setWriteErr(err)
+			return err
+		}
+		records = append(records, record)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.queue <- batch{records: records}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Sync waits until points queued before it are written to files
+func (s *Sink) Sync(ctx context.Context) error {
+	written := make(chan struct{})
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSinkClosed
+	}
+	select {
+	case s.queue <- batch{written: written}:
+		s.mu.Unlock()
+	case <-ctx.Done():
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+	select {
+	case <-written:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ping reports an error when sink is closed or its last write failed, e.g. because the disk is full
+func (s *Sink) Ping(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.writeErr != nil {
+		return fmt.Errorf("last write failed:%w", s.writeErr)
+	}
+	return nil
+}
+
+func (s *Sink) setWriteErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeErr = err
+}
+
+// run writes queued batches and finalizes files older than MaxFileAge even if no more points arrive for them
+func (s *Sink) run() {
+	defer close(s.done)
+	interval := s.opts.MaxFileAge / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case b, ok := <-s.queue:
+			if !ok {
+				s.closeErr = s.finalizeAll()
+				return
+			}
+			if b.written != nil {
+				close(b.written)
+				continue
+			}
+			err := s.writeRecords(b.records)
+			if err != nil {
+				s.log.Error("write sink records failed", zap.Error(err))
+			}
+			s.setWriteErr(err)
+		case <-ticker.C:
+			s.rotateExpired()
+		}
+	}
+}
+
+func (s *Sink) writeRecords(records []*Record) error {
+	for _, record := range records {
+		key := partition{
+			date: record.Timestamp.Format("2006-01-02"),
+			imei: record.IMEI,
+		}
+		file, err := s.partitionFile(key)
+		if err != nil {
+			return err
+		}
+		if e := file.write(record); e != nil {
+			return e
+		}
+		if file.size() >= s.opts.MaxFileSize {
+			if e := s.finalize(key, file); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// Close writes queued points and finalizes all open files
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+	return s.closeErr
+}
+
+func (s *Sink) finalizeAll() error {
+	var errs []error
+	for key, file := range s.files {
+		errs = append(errs, s.finalize(key, file))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sink) finalize(key partition, file *partFile) error {
+	delete(s.files, key)
+	return file.finalize()
+}
+
+func (s *Sink) partitionFile(key partition) (*partFile, error) {
+	if file, found := s.files[key]; found {
+		if time.Since(file.openedAt) < s.opts.MaxFileAge {
+			return file, nil
+		}
+		if err := s.finalize(key, file); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.files) >= s.opts.MaxOpenFiles {
+		if err := s.finalizeLeastRecent(); err != nil {
+			return nil, err
+		}
+	}
+	dir := filepath.Join(s.opts.Dir, "date="+key.date, "imei="+key.imei)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	openedAt := time.Now()
+	name := fmt.Sprintf("%s-%s%s", key.imei, openedAt.UTC().Format("20060102T150405.000000000"), s.extension())
+	file, err := openPartFile(filepath.Join(dir, name), s.opts.Format, s.opts.MaxFileSize, openedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.files[key] = file
+	return file, nil
+}
+
+// finalizeLeastRecent finalizes the file which was written longest ago to keep open files under MaxOpenFiles
+func (s *Sink) finalizeLeastRecent() error {
+	var (
+		oldestKey  partition
+		oldestFile *partFile
+	)
+	for key, file := range s.files {
+		if oldestFile == nil || file.writtenAt.Before(oldestFile.writtenAt) {
+			oldestKey, oldestFile = key, file
+		}
+	}
+	if oldestFile == nil {
+		return nil
+	}
+	return s.finalize(oldestKey, oldestFile)
+}
+
+func (s *Sink) extension() string {
+	if s.opts.Format == FormatParquet {
+		return ".parquet"
+	}
+	return ".ndjson.gz"
+}
+
+func (s *Sink) rotateExpired() {
+	for key, file := range s.files {
+		if time.Since(file.openedAt) < s.opts.MaxFileAge {
+			continue
+		}
+		if err := s.finalize(key, file); err != nil {
+			s.log.Error("finalize sink file failed", zap.Error(err), zap.String("path", file.path))
+		}
+	}
+}
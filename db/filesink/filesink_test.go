@@ -0,0 +1,208 @@
+package filesink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func testPoints(imei string, start time.Time, count int) []*pb.AVLData {
+	points := make([]*pb.AVLData, 0, count)
+	for i := 0; i < count; i++ {
+		points = append(points, &pb.AVLData{
+			Imei:      imei,
+			Timestamp: parser.FormatTimestamp(start.Add(time.Duration(i) * time.Minute)),
+			Priority:  pb.PacketPriority_PACKET_PRIORITY_HIGH,
+			Gps: &pb.GPS{
+				Longitude: 51.4,
+				Latitude:  35.7,
+				Speed:     int32(i),
+			},
+			IoElements: []*pb.IOElement{
+				{ElementName: "Ignition", ElementValue: 1},
+			},
+		})
+	}
+	return points
+}
+
+// sinkFiles returns finalized and temporary files under dir
+func sinkFiles(t *testing.T, dir string) (files, tmpFiles []string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, tmpSuffix) {
+			tmpFiles = append(tmpFiles, path)
+		} else {
+			files = append(files, path)
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+	return files, tmpFiles
+}
+
+func readNDJSON(t *testing.T, path string) []*Record {
+	t.Helper()
+	file, err := os.Open(path)
+	assert.NilError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	assert.NilError(t, err)
+	var records []*Record
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		record := &Record{}
+		assert.NilError(t, json.Unmarshal(scanner.Bytes(), record))
+		records = append(records, record)
+	}
+	assert.NilError(t, scanner.Err())
+	return records
+}
+
+func TestSinkNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(Options{Dir: dir, Format: FormatNDJSON}, zap.NewNop())
+	assert.NilError(t, err)
+	start := time.Date(2023, 8, 1, 23, 58, 0, 0, parser.TimestampLocation())
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 4)))
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("352094087982671", start, 1)))
+	assert.NilError(t, sink.Sync(context.Background()))
+	files, tmpFiles := sinkFiles(t, dir)
+	assert.Equal(t, len(files), 0)
+	assert.Equal(t, len(tmpFiles), 3)
+	assert.NilError(t, sink.Close())
+
+	files, tmpFiles = sinkFiles(t, dir)
+	assert.Equal(t, len(tmpFiles), 0)
+	assert.Equal(t, len(files), 3)
+	counts := make(map[string]int)
+	for _, file := range files {
+		partition, err := filepath.Rel(dir, filepath.Dir(file))
+		assert.NilError(t, err)
+		records := readNDJSON(t, file)
+		for _, record := range records {
+			assert.Equal(t, partition, filepath.Join("date="+record.Timestamp.Format("2006-01-02"), "imei="+record.IMEI))
+			assert.Equal(t, record.IOElements["Ignition"], 1.0)
+		}
+		counts[partition] += len(records)
+	}
+	assert.DeepEqual(t, counts, map[string]int{
+		filepath.Join("date=2023-08-01", "imei=356307042441013"): 2,
+		filepath.Join("date=2023-08-02", "imei=356307042441013"): 2,
+		filepath.Join("date=2023-08-01", "imei=352094087982671"): 1,
+	})
+}
+
+func TestSinkRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(Options{Dir: dir, Format: FormatNDJSON, MaxFileSize: 1}, zap.NewNop())
+	assert.NilError(t, err)
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, parser.TimestampLocation())
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 3)))
+	assert.NilError(t, sink.Sync(context.Background()))
+	files, tmpFiles := sinkFiles(t, dir)
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, len(tmpFiles), 0)
+	assert.NilError(t, sink.Close())
+}
+
+func TestSinkRotateParquetBySize(t *testing.T) {
+	dir := t.TempDir()
+	// rows stay in memory far below the default row group size, they must still count towards rotation
+	sink, err := NewSink(Options{Dir: dir, Format: FormatParquet, MaxFileSize: 4096}, zap.NewNop())
+	assert.NilError(t, err)
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, parser.TimestampLocation())
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 100)))
+	assert.NilError(t, sink.Close())
+	files, _ := sinkFiles(t, dir)
+	assert.Assert(t, len(files) > 1)
+}
+
+func TestSinkMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(Options{Dir: dir, Format: FormatNDJSON, MaxOpenFiles: 2}, zap.NewNop())
+	assert.NilError(t, err)
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, parser.TimestampLocation())
+	ctx := context.Background()
+	for _, imei := range []string{"356307042441013", "352094087982671", "356307042441013", "862462039112434"} {
+		assert.NilError(t, sink.SaveAvlPoints(ctx, testPoints(imei, start, 1)))
+	}
+	assert.NilError(t, sink.Sync(ctx))
+	files, tmpFiles := sinkFiles(t, dir)
+	assert.Equal(t, len(tmpFiles), 2)
+	// least recently written partition is finalized first
+	assert.Equal(t, len(files), 1)
+	assert.Assert(t, strings.Contains(files[0], "imei=352094087982671"))
+	assert.NilError(t, sink.Close())
+}
+
+func TestSinkRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(Options{Dir: dir, Format: FormatNDJSON, MaxFileAge: time.Millisecond * 20}, zap.NewNop())
+	assert.NilError(t, err)
+	defer sink.Close()
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, parser.TimestampLocation())
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 1)))
+	deadline := time.Now().Add(time.Second)
+	files, _ := sinkFiles(t, dir)
+	for len(files) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+		files, _ = sinkFiles(t, dir)
+	}
+	assert.Equal(t, len(files), 1)
+}
+
+func TestSinkParquet(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(Options{Dir: dir, Format: FormatParquet}, zap.NewNop())
+	assert.NilError(t, err)
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, parser.TimestampLocation())
+	assert.NilError(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 10)))
+	assert.NilError(t, sink.Close())
+	assert.ErrorIs(t, sink.SaveAvlPoints(context.Background(), testPoints("356307042441013", start, 1)), ErrSinkClosed)
+
+	files, _ := sinkFiles(t, dir)
+	assert.Equal(t, len(files), 1)
+	assert.Assert(t, strings.HasSuffix(files[0], ".parquet"))
+	data, err := os.ReadFile(files[0])
+	assert.NilError(t, err)
+	magic := []byte("PAR1")
+	assert.Assert(t, bytes.HasPrefix(data, magic) && bytes.HasSuffix(data, magic))
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.ErrorIs(t, (&Options{Format: FormatNDJSON}).Validate(), ErrMissingDir)
+	assert.ErrorIs(t, (&Options{Dir: "out", Format: "csv"}).Validate(), ErrInvalidFormat)
+	assert.NilError(t, (&Options{Dir: "out", Format: FormatParquet}).Validate())
+}
+
+func TestSinkPing(t *testing.T) {
+	sink, err := NewSink(Options{Dir: t.TempDir(), Format: FormatNDJSON}, zap.NewNop())
+	assert.NilError(t, err)
+	ctx := context.Background()
+	assert.NilError(t, sink.Ping(ctx))
+
+	assert.Assert(t, sink.SaveAvlPoints(ctx, []*pb.AVLData{{Imei: "356307042441013", Timestamp: "invalid"}}) != nil)
+	assert.ErrorContains(t, sink.Ping(ctx), "last write failed")
+	assert.NilError(t, sink.SaveAvlPoints(ctx, testPoints("356307042441013", time.Now(), 1)))
+	assert.NilError(t, sink.Sync(ctx))
+	assert.NilError(t, sink.Ping(ctx))
+
+	assert.NilError(t, sink.Close())
+	assert.ErrorIs(t, sink.Ping(ctx), ErrSinkClosed)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/parser"
+)
+
+var (
+	ErrUnknownIOColumn   = errors.New("unknown io column")
+	ErrDuplicateIOSource = errors.New("io element mapped to more than one column")
+)
+
+// IOColumns are typed avlpoints columns io elements can be mapped to, in insert order
+var IOColumns = []string{
+	"ignition",
+	"vehicle_speed",
+	"engine_rpm",
+	"fuel_level",
+	"coolant_temperature",
+	"external_voltage",
+	"battery_voltage",
+	"odometer",
+}
+
+// DefaultIOColumns maps typed columns to io ids or to names of elements derived from eight byte values
+var DefaultIOColumns = map[string][]string{
+	"ignition":            {"239"},
+	"vehicle_speed":       {"VehicleSpeed"},
+	"engine_rpm":          {"EngineSpeed_RPM"},
+	"fuel_level":          {"FuelLevelinTank"},
+	"coolant_temperature": {"EngineCoolantTemperature"},
+	"external_voltage":    {"66"},
+	"battery_voltage":     {"67"},
+	"odometer":            {"16"},
+}
+
+// DefaultIOColumnMapping is used by backends when no mapping is configured
+var DefaultIOColumnMapping = mustIOColumnMapping(DefaultIOColumns)
+
+// IOColumnMapping decides which io elements are written to typed columns
+type IOColumnMapping struct {
+	idIndex   map[uint16]int
+	nameIndex map[string]int
+}
+
+// NewIOColumnMapping creates mapping from typed column name to io ids or element names,
+// names the decoder gives to an io id are resolved to that id
+func NewIOColumnMapping(columns map[string][]string) (*IOColumnMapping, error) {
+	mapping := &IOColumnMapping{
+		idIndex:   make(map[uint16]int),
+		nameIndex: make(map[string]int),
+	}
+	for column, elementNames := range columns {
+		index := -1
+		for i, name := range IOColumns {
+			if name == column {
+				index = i
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIOColumn, column)
+		}
+		for _, elementName := range elementNames {
+			if id, found := parser.IOElementID(elementName); found {
+				if current, mapped := mapping.idIndex[id]; mapped && current != index {
+					return nil, fmt.Errorf("%w: %s", ErrDuplicateIOSource, elementName)
+				}
+				mapping.idIndex[id] = index
+				continue
+			}
+			if _, found := mapping.nameIndex[elementName]; found {
+				return nil, fmt.Errorf("%w: %s", ErrDuplicateIOSource, elementName)
+			}
+			mapping.nameIndex[elementName] = index
+		}
+	}
+	return mapping, nil
+}
+
+func mustIOColumnMapping(columns map[string][]string) *IOColumnMapping {
+	mapping, err := NewIOColumnMapping(columns)
+	if err != nil {
+		panic(err)
+	}
+	return mapping
+}
+
+// LoadIOColumnMapping reads mapping from a json file like {"engine_rpm": ["EngineSpeed_RPM"]}
+func LoadIOColumnMapping(path string) (*IOColumnMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string][]string)
+	if e := json.Unmarshal(content, &columns); e != nil {
+		return nil, fmt.Errorf("decode io column mapping failed:%v", e)
+	}
+	return NewIOColumnMapping(columns)
+}
+
+// Split returns typed column values in IOColumns order and the remaining elements keyed by io id,
+// elements derived from eight byte values are only kept when a typed column maps them
+func (m *IOColumnMapping) Split(elements []*pb.IOElement) ([]*float64, map[uint16]float64) {
+	typed := make([]*float64, len(IOColumns))
+	unknown := make(map[uint16]float64)
+	for _, element := range elements {
+		value := element.GetElementValue()
+		id, hasID := parser.IOElementID(element.GetElementName())
+		if hasID {
+			if index, found := m.idIndex[id]; found {
+				typed[index] = &value
+				continue
+			}
+			unknown[id] = value
+			continue
+		}
+		if index, found := m.nameIndex[element.GetElementName()]; found {
+			typed[index] = &value
+		}
+	}
+	return typed, unknown
+}
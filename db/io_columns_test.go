@@ -0,0 +1,109 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestIOColumnMapping_Split(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	tests := map[string]struct {
+		columns     map[string][]string
+		elements    []*pb.IOElement
+		wantTyped   map[string]*float64
+		wantUnknown map[uint16]float64
+	}{
+		"default mapping": {
+			columns: DefaultIOColumns,
+			elements: []*pb.IOElement{
+				{ElementName: "Ignition", ElementValue: 1},
+				{ElementName: "EngineSpeed_RPM", ElementValue: 1850},
+				{ElementName: "16", ElementValue: 125000},
+				{ElementName: "11", ElementValue: 893700218},
+				{ElementName: "CheckEngine", ElementValue: 0},
+				{ElementName: "ExternalVoltage", ElementValue: 12400},
+				{ElementName: "GSMSignal", ElementValue: 4},
+			},
+			wantTyped: map[string]*float64{
+				"ignition":         float(1),
+				"engine_rpm":       float(1850),
+				"odometer":         float(125000),
+				"external_voltage": float(12400),
+			},
+			wantUnknown: map[uint16]float64{11: 893700218, 21: 4},
+		},
+		"named element by io id": {
+			columns: map[string][]string{"ignition": {"239"}},
+			elements: []*pb.IOElement{
+				{ElementName: "Ignition", ElementValue: 1},
+				{ElementName: "DigitalInput1", ElementValue: 0},
+			},
+			wantTyped: map[string]*float64{
+				"ignition": float(1),
+			},
+			wantUnknown: map[uint16]float64{1: 0},
+		},
+		"custom mapping": {
+			columns: map[string][]string{"fuel_level": {"11"}},
+			elements: []*pb.IOElement{
+				{ElementName: "11", ElementValue: 42},
+				{ElementName: "16", ElementValue: 125000},
+			},
+			wantTyped: map[string]*float64{
+				"fuel_level": float(42),
+			},
+			wantUnknown: map[uint16]float64{16: 125000},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mapping, err := NewIOColumnMapping(test.columns)
+			assert.NilError(t, err)
+			typed, unknown := mapping.Split(test.elements)
+			assert.Equal(t, len(typed), len(IOColumns))
+			for i, column := range IOColumns {
+				assert.DeepEqual(t, typed[i], test.wantTyped[column])
+			}
+			assert.DeepEqual(t, unknown, test.wantUnknown)
+		})
+	}
+}
+
+func TestLoadIOColumnMapping(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		errWant error
+	}{
+		"success": {
+			content: `{"engine_rpm": ["EngineSpeed_RPM", "36"]}`,
+		},
+		"unknown column": {
+			content: `{"gear": ["GearPosition"]}`,
+			errWant: ErrUnknownIOColumn,
+		},
+		"duplicate element": {
+			content: `{"engine_rpm": ["36"], "vehicle_speed": ["36"]}`,
+			errWant: ErrDuplicateIOSource,
+		},
+		"duplicate io id by name": {
+			content: `{"ignition": ["239"], "engine_rpm": ["Ignition"]}`,
+			errWant: ErrDuplicateIOSource,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "io_columns.json")
+			assert.NilError(t, os.WriteFile(path, []byte(test.content), 0o600))
+			_, err := LoadIOColumnMapping(path)
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
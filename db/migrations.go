@@ -0,0 +1,83 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidMigrationName = errors.New("invalid migration file name")
+	ErrMissingMigration     = errors.New("migration must have up and down files")
+	migrationNameRegex      = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	tableNameRegex          = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+// Migration is a versioned schema change embedded in the binary
+type Migration struct {
+	Version uint32
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration is applied to database
+type MigrationStatus struct {
+	*Migration
+	Applied   bool
+	ChangedAt time.Time
+}
+
+// LoadMigrations reads NNNN_name.up.sql and NNNN_name.down.sql files of dir sorted by version
+func LoadMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint32]*Migration)
+	for _, entry := range entries {
+		matches := migrationNameRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, entry.Name())
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, found := byVersion[uint32(version)]
+		if !found {
+			migration = &Migration{Version: uint32(version), Name: matches[2]}
+			byVersion[uint32(version)] = migration
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrMissingMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ValidTableName reports whether name can be used as a table name in a query
+func ValidTableName(name string) bool {
+	return tableNameRegex.MatchString(name)
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := map[string]struct {
+		files   fstest.MapFS
+		errWant error
+	}{
+		"invalid name": {
+			files: fstest.MapFS{
+				"migrations/create_table.sql": {Data: []byte("SELECT 1;")},
+			},
+			errWant: ErrInvalidMigrationName,
+		},
+		"missing down": {
+			files: fstest.MapFS{
+				"migrations/0001_create_table.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			errWant: ErrMissingMigration,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMigrations(test.files, "migrations")
+			assert.ErrorIs(t, err, test.errWant)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: db.go
+
+// Package mock_db is a generated GoMock package.
+package mock_db
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	devicev1 "github.com/irisco88/protos/gen/device/v1"
+	db "github.com/irisco88/teltonika-device/db"
+)
+
+// MockPointSink is a mock of PointSink interface.
+type MockPointSink struct {
+	ctrl     *gomock.Controller
+	recorder *MockPointSinkMockRecorder
+}
+
+// MockPointSinkMockRecorder is the mock recorder for MockPointSink.
+type MockPointSinkMockRecorder struct {
+	mock *MockPointSink
+}
+
+// NewMockPointSink creates a new mock instance.
+func NewMockPointSink(ctrl *gomock.Controller) *MockPointSink {
+	mock := &MockPointSink{ctrl: ctrl}
+	mock.recorder = &MockPointSinkMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPointSink) EXPECT() *MockPointSinkMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockPointSink) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockPointSinkMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockPointSink)(nil).Close))
+}
+
+// SaveAvlPoints mocks base method.
+func (m *MockPointSink) SaveAvlPoints(ctx context.Context, points []*devicev1.AVLData) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAvlPoints", ctx, points)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAvlPoints indicates an expected call of SaveAvlPoints.
+func (mr *MockPointSinkMockRecorder) SaveAvlPoints(ctx, points interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAvlPoints", reflect.TypeOf((*MockPointSink)(nil).SaveAvlPoints), ctx, points)
+}
+
+// MockAVLDBConn is a mock of AVLDBConn interface.
+type MockAVLDBConn struct {
+	ctrl     *gomock.Controller
+	recorder *MockAVLDBConnMockRecorder
+}
+
+// MockAVLDBConnMockRecorder is the mock recorder for MockAVLDBConn.
+type MockAVLDBConnMockRecorder struct {
+	mock *MockAVLDBConn
+}
+
+// NewMockAVLDBConn creates a new mock instance.
+func NewMockAVLDBConn(ctrl *gomock.Controller) *MockAVLDBConn {
+	mock := &MockAVLDBConn{ctrl: ctrl}
+	mock.recorder = &MockAVLDBConnMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAVLDBConn) EXPECT() *MockAVLDBConnMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockAVLDBConn) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockAVLDBConnMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockAVLDBConn)(nil).Close))
+}
+
+// CountRawData mocks base method.
+func (m *MockAVLDBConn) CountRawData(ctx context.Context, filter *db.RawFrameFilter) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRawData", ctx, filter)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRawData indicates an expected call of CountRawData.
+func (mr *MockAVLDBConnMockRecorder) CountRawData(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRawData", reflect.TypeOf((*MockAVLDBConn)(nil).CountRawData), ctx, filter)
+}
+
+// CreateTableLike mocks base method.
+func (m *MockAVLDBConn) CreateTableLike(ctx context.Context, table, source string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTableLike", ctx, table, source)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTableLike indicates an expected call of CreateTableLike.
+func (mr *MockAVLDBConnMockRecorder) CreateTableLike(ctx, table, source interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTableLike", reflect.TypeOf((*MockAVLDBConn)(nil).CreateTableLike), ctx, table, source)
+}
+
+// LoadAvlPointsAt mocks base method.
+func (m *MockAVLDBConn) LoadAvlPointsAt(ctx context.Context, table, imei string, timestamps []string) ([]*devicev1.AVLData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadAvlPointsAt", ctx, table, imei, timestamps)
+	ret0, _ := ret[0].([]*devicev1.AVLData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadAvlPointsAt indicates an expected call of LoadAvlPointsAt.
+func (mr *MockAVLDBConnMockRecorder) LoadAvlPointsAt(ctx, table, imei, timestamps interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAvlPointsAt", reflect.TypeOf((*MockAVLDBConn)(nil).LoadAvlPointsAt), ctx, table, imei, timestamps)
+}
+
+// LoadRawData mocks base method.
+func (m *MockAVLDBConn) LoadRawData(ctx context.Context, filter *db.RawFrameFilter, fn func(*db.RawFrame) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadRawData", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LoadRawData indicates an expected call of LoadRawData.
+func (mr *MockAVLDBConnMockRecorder) LoadRawData(ctx, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadRawData", reflect.TypeOf((*MockAVLDBConn)(nil).LoadRawData), ctx, filter, fn)
+}
+
+// LoadTrack mocks base method.
+func (m *MockAVLDBConn) LoadTrack(ctx context.Context, imei string, from, to time.Time, limit int) ([]*devicev1.AVLData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTrack", ctx, imei, from, to, limit)
+	ret0, _ := ret[0].([]*devicev1.AVLData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadTrack indicates an expected call of LoadTrack.
+func (mr *MockAVLDBConnMockRecorder) LoadTrack(ctx, imei, from, to, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTrack", reflect.TypeOf((*MockAVLDBConn)(nil).LoadTrack), ctx, imei, from, to, limit)
+}
+
+// MigrateDown mocks base method.
+func (m *MockAVLDBConn) MigrateDown(ctx context.Context, steps int) ([]*db.Migration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateDown", ctx, steps)
+	ret0, _ := ret[0].([]*db.Migration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateDown indicates an expected call of MigrateDown.
+func (mr *MockAVLDBConnMockRecorder) MigrateDown(ctx, steps interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateDown", reflect.TypeOf((*MockAVLDBConn)(nil).MigrateDown), ctx, steps)
+}
+
+// MigrateUp mocks base method.
+func (m *MockAVLDBConn) MigrateUp(ctx context.Context, steps int) ([]*db.Migration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateUp", ctx, steps)
+	ret0, _ := ret[0].([]*db.Migration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateUp indicates an expected call of MigrateUp.
+func (mr *MockAVLDBConnMockRecorder) MigrateUp(ctx, steps interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateUp", reflect.TypeOf((*MockAVLDBConn)(nil).MigrateUp), ctx, steps)
+}
+
+// MigrationStatuses mocks base method.
+func (m *MockAVLDBConn) MigrationStatuses(ctx context.Context) ([]*db.MigrationStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrationStatuses", ctx)
+	ret0, _ := ret[0].([]*db.MigrationStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrationStatuses indicates an expected call of MigrationStatuses.
+func (mr *MockAVLDBConnMockRecorder) MigrationStatuses(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrationStatuses", reflect.TypeOf((*MockAVLDBConn)(nil).MigrationStatuses), ctx)
+}
+
+// Ping mocks base method.
+func (m *MockAVLDBConn) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockAVLDBConnMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockAVLDBConn)(nil).Ping), ctx)
+}
+
+// SaveAvlPoints mocks base method.
+func (m *MockAVLDBConn) SaveAvlPoints(ctx context.Context, points []*devicev1.AVLData) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAvlPoints", ctx, points)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAvlPoints indicates an expected call of SaveAvlPoints.
+func (mr *MockAVLDBConnMockRecorder) SaveAvlPoints(ctx, points interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAvlPoints", reflect.TypeOf((*MockAVLDBConn)(nil).SaveAvlPoints), ctx, points)
+}
+
+// SaveAvlPointsToTable mocks base method.
+func (m *MockAVLDBConn) SaveAvlPointsToTable(ctx context.Context, table string, points []*devicev1.AVLData) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAvlPointsToTable", ctx, table, points)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAvlPointsToTable indicates an expected call of SaveAvlPointsToTable.
+func (mr *MockAVLDBConnMockRecorder) SaveAvlPointsToTable(ctx, table, points interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAvlPointsToTable", reflect.TypeOf((*MockAVLDBConn)(nil).SaveAvlPointsToTable), ctx, table, points)
+}
+
+// SaveRawData mocks base method.
+func (m *MockAVLDBConn) SaveRawData(ctx context.Context, frame *db.RawFrame) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRawData", ctx, frame)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRawData indicates an expected call of SaveRawData.
+func (mr *MockAVLDBConnMockRecorder) SaveRawData(ctx, frame interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRawData", reflect.TypeOf((*MockAVLDBConn)(nil).SaveRawData), ctx, frame)
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ db.AVLDBConn = &AVLDataBase{}
+
+type AVLDataBase struct {
+	Pool *pgxpool.Pool
+	// IOColumns maps io elements to typed avlpoints columns, DefaultIOColumns is used when nil
+	IOColumns *db.IOColumnMapping
+}
+
+func ConnectAvlDB(databaseURL string) (*AVLDataBase, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxConns = 5
+	config.MaxConnLifetime = time.Duration(10) * time.Minute
+	config.ConnConfig.ConnectTimeout = time.Second * 30
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	if e := pool.Ping(context.Background()); e != nil {
+		pool.Close()
+		return nil, e
+	}
+	return &AVLDataBase{
+		Pool: pool,
+	}, nil
+}
+
+func (adb *AVLDataBase) Ping(ctx context.Context) error {
+	return adb.Pool.Ping(ctx)
+}
+
+func (adb *AVLDataBase) Close() error {
+	adb.Pool.Close()
+	return nil
+}
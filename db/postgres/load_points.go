@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/jackc/pgx/v5"
+)
+
+// rows are appended on reprocess, DISTINCT ON keeps the latest inserted row per timestamp
+const selectAvlPointsQuery = `
+	SELECT DISTINCT ON ("timestamp") imei, "timestamp", priority, longitude, latitude, altitude, angle, satellites, speed, event_id, io_elements
+	FROM %s
+	WHERE imei = $1 AND "timestamp" = ANY($2)
+	ORDER BY "timestamp", inserted_at DESC;
+`
+
+const selectTrackQuery = `
+	SELECT DISTINCT ON ("timestamp") imei, "timestamp", priority, longitude, latitude, altitude, angle, satellites, speed, event_id, io_elements
+	FROM avlpoints
+	WHERE imei = $1 AND "timestamp" >= $2 AND "timestamp" < $3
+	ORDER BY "timestamp", inserted_at DESC
+	LIMIT $4;
+`
+
+const createTableLikeQuery = `
+	CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL);
+`
+
+// LoadAvlPointsAt loads stored points of imei from table at the given timestamps
+func (adb *AVLDataBase) LoadAvlPointsAt(ctx context.Context, table, imei string, timestamps []string) ([]*pb.AVLData, error) {
+	if !db.ValidTableName(table) {
+		return nil, db.ErrInvalidTableName
+	}
+	if len(timestamps) == 0 {
+		return nil, nil
+	}
+	times := make([]time.Time, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		parsed, err := parser.ParseTimestamp(timestamp)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, parsed)
+	}
+	rows, err := adb.Pool.Query(ctx, fmt.Sprintf(selectAvlPointsQuery, tableIdentifier(table).Sanitize()), imei, times)
+	if err != nil {
+		return nil, err
+	}
+	return scanAvlPoints(rows)
+}
+
+// LoadTrack loads at most limit points of imei stored in [from, to) ordered by timestamp
+func (adb *AVLDataBase) LoadTrack(ctx context.Context, imei string, from, to time.Time, limit int) ([]*pb.AVLData, error) {
+	rows, err := adb.Pool.Query(ctx, selectTrackQuery, imei, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAvlPoints(rows)
+}
+
+// CreateTableLike creates table with the same columns and indexes as source if it does not exist
+func (adb *AVLDataBase) CreateTableLike(ctx context.Context, table, source string) error {
+	if !db.ValidTableName(table) || !db.ValidTableName(source) {
+		return db.ErrInvalidTableName
+	}
+	_, err := adb.Pool.Exec(ctx, fmt.Sprintf(createTableLikeQuery,
+		tableIdentifier(table).Sanitize(),
+		tableIdentifier(source).Sanitize(),
+	))
+	return err
+}
+
+func scanAvlPoints(rows pgx.Rows) ([]*pb.AVLData, error) {
+	defer rows.Close()
+	var points []*pb.AVLData
+	for rows.Next() {
+		var (
+			point      = &pb.AVLData{Gps: &pb.GPS{}}
+			timestamp  time.Time
+			priority   string
+			altitude   int16
+			angle      int16
+			satellites int16
+			speed      int16
+			eventID    int32
+			elements   map[string]float64
+		)
+		if e := rows.Scan(
+			&point.Imei,
+			&timestamp,
+			&priority,
+			&point.Gps.Longitude,
+			&point.Gps.Latitude,
+			&altitude,
+			&angle,
+			&satellites,
+			&speed,
+			&eventID,
+			&elements,
+		); e != nil {
+			return nil, fmt.Errorf("scan avl point failed:%v", e)
+		}
+		point.Timestamp = parser.FormatTimestamp(timestamp)
+		point.Priority = pb.PacketPriority(pb.PacketPriority_value[priority])
+		point.Gps.Altitude = int32(altitude)
+		point.Gps.Angle = int32(angle)
+		point.Gps.Satellites = int32(satellites)
+		point.Gps.Speed = int32(speed)
+		point.EventId = uint32(eventID)
+		point.IoElements = elementsFromMap(elements)
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// elementsFromMap converts stored io_elements to io elements sorted by name
+func elementsFromMap(elements map[string]float64) []*pb.IOElement {
+	result := make([]*pb.IOElement, 0, len(elements))
+	for name, value := range elements {
+		result = append(result, &pb.IOElement{
+			ElementName:  name,
+			ElementValue: value,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ElementName < result[j].ElementName
+	})
+	return result
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/irisco88/teltonika-device/db"
+)
+
+const selectRawDataQuery = `
+	SELECT imei, received_at, remote_addr, codec_id, record_count, crc_ok, parse_error, payload
+	FROM rawdatas
+	WHERE imei = ANY($1) AND received_at >= $2 AND received_at < $3
+	ORDER BY imei, received_at;
+`
+
+const countRawDataQuery = `
+	SELECT count(*)
+	FROM rawdatas
+	WHERE imei = ANY($1) AND received_at >= $2 AND received_at < $3;
+`
+
+// CountRawData counts archived raw frames matching filter
+func (adb *AVLDataBase) CountRawData(ctx context.Context, filter *db.RawFrameFilter) (uint64, error) {
+	var count int64
+	row := adb.Pool.QueryRow(ctx, countRawDataQuery, filter.IMEIs, filter.From, filter.To)
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+// LoadRawData streams archived raw frames matching filter to fn, ordered by imei and receive time
+func (adb *AVLDataBase) LoadRawData(ctx context.Context, filter *db.RawFrameFilter, fn func(frame *db.RawFrame) error) error {
+	rows, err := adb.Pool.Query(ctx, selectRawDataQuery, filter.IMEIs, filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			frame       db.RawFrame
+			codecID     int16
+			recordCount int16
+		)
+		if e := rows.Scan(
+			&frame.IMEI,
+			&frame.ReceivedAt,
+			&frame.RemoteAddr,
+			&codecID,
+			&recordCount,
+			&frame.CRCValid,
+			&frame.ParseError,
+			&frame.Payload,
+		); e != nil {
+			return fmt.Errorf("scan raw data failed:%v", e)
+		}
+		frame.CodecID = uint8(codecID)
+		frame.RecordCount = uint8(recordCount)
+		if e := fn(&frame); e != nil {
+			return e
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const createMigrationsTableQuery = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+	    version    integer PRIMARY KEY,
+	    name       text        NOT NULL,
+	    applied_at timestamptz NOT NULL DEFAULT now()
+	);
+`
+
+const selectMigrationsQuery = `
+	SELECT version, applied_at FROM schema_migrations;
+`
+
+const insertMigrationQuery = `
+	INSERT INTO schema_migrations(version, name) VALUES ($1, $2);
+`
+
+const deleteMigrationQuery = `
+	DELETE FROM schema_migrations WHERE version = $1;
+`
+
+// LoadMigrations reads embedded postgres migrations sorted by version
+func LoadMigrations() ([]*db.Migration, error) {
+	return db.LoadMigrations(migrationFiles, "migrations")
+}
+
+// MigrationStatuses returns state of every embedded migration
+func (adb *AVLDataBase) MigrationStatuses(ctx context.Context) ([]*db.MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, e := adb.Pool.Exec(ctx, createMigrationsTableQuery); e != nil {
+		return nil, fmt.Errorf("create migrations table failed:%v", e)
+	}
+	rows, err := adb.Pool.Query(ctx, selectMigrationsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[uint32]*db.MigrationStatus)
+	for rows.Next() {
+		status := &db.MigrationStatus{Applied: true}
+		var version int32
+		if e := rows.Scan(&version, &status.ChangedAt); e != nil {
+			return nil, fmt.Errorf("scan migration failed:%v", e)
+		}
+		applied[uint32(version)] = status
+	}
+	if e := rows.Err(); e != nil {
+		return nil, e
+	}
+	statuses := make([]*db.MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status, found := applied[migration.Version]
+		if !found {
+			status = &db.MigrationStatus{}
+		}
+		status.Migration = migration
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies at most steps pending migrations in version order, zero steps applies all
+func (adb *AVLDataBase) MigrateUp(ctx context.Context, steps int) ([]*db.Migration, error) {
+	statuses, err := adb.MigrationStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []*db.Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if e := adb.runMigration(ctx, status.Migration, true); e != nil {
+			return done, e
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts at most steps applied migrations in reverse version order, zero steps reverts all
+func (adb *AVLDataBase) MigrateDown(ctx context.Context, steps int) ([]*db.Migration, error) {
+	statuses, err := adb.MigrationStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []*db.Migration
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if e := adb.runMigration(ctx, status.Migration, false); e != nil {
+			return done, e
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// runMigration runs migration script and records it in one transaction
+func (adb *AVLDataBase) runMigration(ctx context.Context, migration *db.Migration, up bool) error {
+	return pgx.BeginFunc(ctx, adb.Pool, func(tx pgx.Tx) error {
+		script, recordQuery, recordArgs := migration.Down, deleteMigrationQuery, []any{int32(migration.Version)}
+		if up {
+			script, recordQuery, recordArgs = migration.Up, insertMigrationQuery, []any{int32(migration.Version), migration.Name}
+		}
+		if _, e := tx.Exec(ctx, script); e != nil {
+			return fmt.Errorf("migration %04d_%s failed:%v", migration.Version, migration.Name, e)
+		}
+		_, e := tx.Exec(ctx, recordQuery, recordArgs...)
+		return e
+	})
+}
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.NilError(t, err)
+	assert.Assert(t, len(migrations) > 0)
+	for i, migration := range migrations {
+		assert.Equal(t, migration.Version, uint32(i+1))
+	}
+}
@@ -0,0 +1,41 @@
+-- decoded avl records, one row per record
+-- reprocessed rows are appended, readers keep the latest inserted row per (imei, timestamp)
+CREATE TABLE IF NOT EXISTS avlpoints
+(
+    imei                text             NOT NULL,
+    "timestamp"         timestamptz      NOT NULL,
+    priority            text             NOT NULL,
+    longitude           double precision NOT NULL,
+    latitude            double precision NOT NULL,
+    altitude            smallint         NOT NULL,
+    angle               smallint         NOT NULL,
+    satellites          smallint         NOT NULL,
+    speed               smallint         NOT NULL,
+    event_id            integer          NOT NULL,
+    io_elements         jsonb            NOT NULL DEFAULT '{}',
+    parser_version      text             NOT NULL DEFAULT '',
+    io_by_id            jsonb            NOT NULL DEFAULT '{}',
+    ignition            double precision,
+    vehicle_speed       double precision,
+    engine_rpm          double precision,
+    fuel_level          double precision,
+    coolant_temperature double precision,
+    external_voltage    double precision,
+    battery_voltage     double precision,
+    odometer            double precision,
+    inserted_at         timestamptz      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS avlpoints_imei_timestamp_idx ON avlpoints (imei, "timestamp" DESC);
+
+-- monthly chunks and retention when timescaledb is installed, plain table otherwise
+DO
+$$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb') THEN
+        CREATE EXTENSION IF NOT EXISTS timescaledb;
+        PERFORM create_hypertable('avlpoints', 'timestamp', chunk_time_interval => INTERVAL '1 month', if_not_exists => TRUE);
+        PERFORM add_retention_policy('avlpoints', INTERVAL '2 years', if_not_exists => TRUE);
+    END IF;
+END
+$$;
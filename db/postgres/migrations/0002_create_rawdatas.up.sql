@@ -0,0 +1,24 @@
+-- raw frames exactly as received from devices, used for replay and reprocessing
+CREATE TABLE IF NOT EXISTS rawdatas
+(
+    imei         text        NOT NULL,
+    received_at  timestamptz NOT NULL,
+    remote_addr  text        NOT NULL,
+    codec_id     smallint    NOT NULL,
+    record_count smallint    NOT NULL,
+    crc_ok       boolean     NOT NULL,
+    parse_error  text        NOT NULL DEFAULT '',
+    payload      bytea       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rawdatas_imei_received_at_idx ON rawdatas (imei, received_at);
+
+DO
+$$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+        PERFORM create_hypertable('rawdatas', 'received_at', chunk_time_interval => INTERVAL '1 month', if_not_exists => TRUE);
+        PERFORM add_retention_policy('rawdatas', INTERVAL '1 year', if_not_exists => TRUE);
+    END IF;
+END
+$$;
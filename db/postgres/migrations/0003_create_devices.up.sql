@@ -0,0 +1,9 @@
+-- device registry consulted on connect, only active devices may send data
+CREATE TABLE IF NOT EXISTS devices
+(
+    imei       text        PRIMARY KEY,
+    name       text        NOT NULL DEFAULT '',
+    status     text        NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'disabled', 'blocked')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/jackc/pgx/v5"
+)
+
+var avlPointColumns = append([]string{
+	"imei", "timestamp", "priority", "longitude", "latitude", "altitude", "angle", "satellites", "speed", "event_id",
+	"io_elements", "parser_version", "io_by_id",
+}, db.IOColumns...)
+
+// SaveAvlPoints saves avl points to postgres
+func (adb *AVLDataBase) SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error {
+	return adb.SaveAvlPointsToTable(ctx, db.AvlPointsTable, points)
+}
+
+// SaveAvlPointsToTable copies avl points to the given table which must have avlpoints structure
+func (adb *AVLDataBase) SaveAvlPointsToTable(ctx context.Context, table string, points []*pb.AVLData) error {
+	if !db.ValidTableName(table) {
+		return db.ErrInvalidTableName
+	}
+	rows := make([][]any, 0, len(points))
+	for _, point := range points {
+		timestamp, err := parser.ParseTimestamp(point.GetTimestamp())
+		if err != nil {
+			return err
+		}
+		gps := point.GetGps()
+		elementMap := make(map[string]float64, len(point.GetIoElements()))
+		for _, element := range point.GetIoElements() {
+			elementMap[element.GetElementName()] = element.GetElementValue()
+		}
+		typedValues, unknownElements := adb.ioColumnMapping().Split(point.GetIoElements())
+		row := []any{
+			point.GetImei(),
+			timestamp,
+			point.GetPriority().String(),
+			gps.GetLongitude(),
+			gps.GetLatitude(),
+			int16(gps.GetAltitude()),
+			int16(gps.GetAngle()),
+			int16(gps.GetSatellites()),
+			int16(gps.GetSpeed()),
+			int32(point.GetEventId()),
+			elementMap,
+			parser.Version,
+			unknownElements,
+		}
+		for _, value := range typedValues {
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	_, err := adb.Pool.CopyFrom(ctx, tableIdentifier(table), avlPointColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+func (adb *AVLDataBase) ioColumnMapping() *db.IOColumnMapping {
+	if adb.IOColumns == nil {
+		return db.DefaultIOColumnMapping
+	}
+	return adb.IOColumns
+}
+
+// tableIdentifier splits optional schema from table name
+func tableIdentifier(table string) pgx.Identifier {
+	return strings.Split(table, ".")
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/irisco88/teltonika-device/db"
+)
+
+const insertRawDataQuery = `
+	INSERT INTO
+	    rawdatas(imei, received_at, remote_addr, codec_id, record_count, crc_ok, parse_error, payload)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8);
+`
+
+// SaveRawData saves raw frame to postgres
+func (adb *AVLDataBase) SaveRawData(ctx context.Context, frame *db.RawFrame) error {
+	_, err := adb.Pool.Exec(ctx, insertRawDataQuery,
+		frame.IMEI,
+		frame.ReceivedAt,
+		frame.RemoteAddr,
+		int16(frame.CodecID),
+		int16(frame.RecordCount),
+		frame.CRCValid,
+		frame.ParseError,
+		frame.Payload,
+	)
+	return err
+}
@@ -0,0 +1,61 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	gatewayv1 "github.com/irisco88/teltonika-device/proto/gateway/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrMissingToken is returned by NewServer without a token
+var ErrMissingToken = errors.New("grpc api token is required")
+
+// NewServer creates grpc server of service, every call needs token as bearer authorization metadata.
+// opts are passed to grpc.NewServer, e.g. grpc.Creds with tls credentials
+func NewServer(service *Service, token string, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	auth := &tokenAuth{token: []byte(token)}
+	opts = append(opts,
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+	server := grpc.NewServer(opts...)
+	gatewayv1.RegisterGatewayServiceServer(server, service)
+	return server, nil
+}
+
+type tokenAuth struct {
+	token []byte
+}
+
+func (a *tokenAuth) unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !a.authorized(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return handler(ctx, req)
+}
+
+func (a *tokenAuth) stream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !a.authorized(stream.Context()) {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return handler(srv, stream)
+}
+
+func (a *tokenAuth) authorized(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(value, "Bearer "); found && subtle.ConstantTimeCompare([]byte(token), a.token) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/irisco88/teltonika-device/live"
+	gatewayv1 "github.com/irisco88/teltonika-device/proto/gateway/v1"
+	"github.com/irisco88/teltonika-device/session"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Service implements gateway.v1.GatewayService over live points of hub and sessions of the server
+type Service struct {
+	gatewayv1.UnimplementedGatewayServiceServer
+	hub        *live.Hub
+	sessions   *session.Registry
+	bufferSize int
+	log        *zap.Logger
+}
+
+// NewService creates gateway service, every stream buffers up to bufferSize points
+func NewService(hub *live.Hub, sessions *session.Registry, bufferSize int, log *zap.Logger) *Service {
+	return &Service{
+		hub:        hub,
+		sessions:   sessions,
+		bufferSize: bufferSize,
+		log:        log,
+	}
+}
+
+// StreamPoints streams points until the client cancels or the hub is closed,
+// points are dropped while the client is slower than the devices
+func (s *Service) StreamPoints(req *gatewayv1.StreamPointsRequest, stream gatewayv1.GatewayService_StreamPointsServer) error {
+	sub := s.hub.Subscribe(live.IMEIFilter(req.GetImeiList()...), s.bufferSize)
+	defer func() {
+		sub.Close()
+		if dropped := sub.Dropped(); dropped > 0 {
+			s.log.Warn("stream dropped points",
+				zap.Uint64("dropped", dropped),
+				zap.Strings("imeis", req.GetImeiList()),
+			)
+		}
+	}()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case point, ok := <-sub.Points():
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if err := stream.Send(&gatewayv1.StreamPointsResponse{Point: point}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// GetLastPoint returns the last point received from a device since the gateway started.
+// Last points are kept in memory of the live hub only, stored points are not read, so a device
+// that has not reported since a restart is not found; use the track api for stored points
+func (s *Service) GetLastPoint(_ context.Context, req *gatewayv1.GetLastPointRequest) (*gatewayv1.GetLastPointResponse, error) {
+	if req.GetImei() == "" {
+		return nil, status.Error(codes.InvalidArgument, "imei is required")
+	}
+	point, found := s.hub.LastPoint(req.GetImei())
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "no point received from %s", req.GetImei())
+	}
+	return &gatewayv1.GetLastPointResponse{Point: point}, nil
+}
+
+// ListSessions returns connected devices ordered by imei
+func (s *Service) ListSessions(context.Context, *gatewayv1.ListSessionsRequest) (*gatewayv1.ListSessionsResponse, error) {
+	infos := s.sessions.List()
+	resp := &gatewayv1.ListSessionsResponse{
+		Sessions: make([]*gatewayv1.Session, 0, len(infos)),
+	}
+	for _, info := range infos {
+		sess := &gatewayv1.Session{
+			Imei:            info.IMEI,
+			RemoteAddr:      info.RemoteAddr,
+			ConnectedAt:     timestamppb.New(info.ConnectedAt),
+			BytesReceived:   info.BytesReceived,
+			FramesReceived:  info.FramesReceived,
+			RecordsReceived: info.RecordsReceived,
+			CodecId:         uint32(info.CodecID),
+			Firmware:        info.Firmware,
+		}
+		if !info.LastPacketAt.IsZero() {
+			sess.LastPacketAt = timestamppb.New(info.LastPacketAt)
+		}
+		resp.Sessions = append(resp.Sessions, sess)
+	}
+	return resp, nil
+}
@@ -0,0 +1,169 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/live"
+	gatewayv1 "github.com/irisco88/teltonika-device/proto/gateway/v1"
+	"github.com/irisco88/teltonika-device/session"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gotest.tools/v3/assert"
+)
+
+const testToken = "secret"
+
+func newTestClient(t *testing.T, hub *live.Hub, sessions *session.Registry) gatewayv1.GatewayServiceClient {
+	return newTestClientWithToken(t, hub, sessions, testToken)
+}
+
+func newTestClientWithToken(t *testing.T, hub *live.Hub, sessions *session.Registry, token string) gatewayv1.GatewayServiceClient {
+	ln := bufconn.Listen(1024 * 1024)
+	grpcServer, err := NewServer(NewService(hub, sessions, 0, zap.NewNop()), testToken)
+	assert.NilError(t, err)
+	go grpcServer.Serve(ln)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(withToken(ctx, token), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withToken(ctx, token), desc, cc, method, opts...)
+		}),
+	)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return gatewayv1.NewGatewayServiceClient(conn)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestNewServer_MissingToken(t *testing.T) {
+	_, err := NewServer(NewService(live.NewHub(), session.NewRegistry(), 0, zap.NewNop()), "")
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestAuth(t *testing.T) {
+	tests := map[string]struct {
+		token string
+		code  codes.Code
+	}{
+		"valid":   {token: testToken, code: codes.OK},
+		"invalid": {token: "wrong", code: codes.Unauthenticated},
+		"missing": {token: "", code: codes.Unauthenticated},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			client := newTestClientWithToken(t, live.NewHub(), session.NewRegistry(), test.token)
+
+			_, err := client.ListSessions(ctx, &gatewayv1.ListSessionsRequest{})
+			assert.Equal(t, status.Code(err), test.code)
+
+			stream, err := client.StreamPoints(ctx, &gatewayv1.StreamPointsRequest{})
+			assert.NilError(t, err)
+			if test.code == codes.OK {
+				return
+			}
+			_, err = stream.Recv()
+			assert.Equal(t, status.Code(err), test.code)
+		})
+	}
+}
+
+func TestStreamPoints(t *testing.T) {
+	hub := live.NewHub()
+	client := newTestClient(t, hub, session.NewRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamPoints(ctx, &gatewayv1.StreamPointsRequest{ImeiList: []string{"356307042441013"}})
+	assert.NilError(t, err)
+	for i := 0; i < 100 && hub.Subscribers() == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, hub.Subscribers(), 1)
+	assert.NilError(t, hub.SaveAvlPoints(ctx, []*pb.AVLData{
+		{Imei: "352094087982671", Timestamp: "2023-09-27 07:33:20"},
+		{Imei: "356307042441013", Timestamp: "2023-09-27 07:33:30"},
+	}))
+	resp, err := stream.Recv()
+	assert.NilError(t, err)
+	assert.Equal(t, resp.GetPoint().GetImei(), "356307042441013")
+
+	assert.NilError(t, hub.Close())
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, status.Code(err), codes.Unavailable)
+}
+
+func TestGetLastPoint(t *testing.T) {
+	hub := live.NewHub()
+	client := newTestClient(t, hub, session.NewRegistry())
+	ctx := context.Background()
+	assert.NilError(t, hub.SaveAvlPoints(ctx, []*pb.AVLData{
+		{Imei: "356307042441013", Timestamp: "2023-09-27 07:33:30"},
+	}))
+
+	tests := map[string]struct {
+		imei string
+		code codes.Code
+	}{
+		"found":     {imei: "356307042441013", code: codes.OK},
+		"not found": {imei: "352094087982671", code: codes.NotFound},
+		"empty":     {imei: "", code: codes.InvalidArgument},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := client.GetLastPoint(ctx, &gatewayv1.GetLastPointRequest{Imei: test.imei})
+			assert.Equal(t, status.Code(err), test.code)
+			if test.code == codes.OK {
+				assert.Equal(t, resp.GetPoint().GetTimestamp(), "2023-09-27 07:33:30")
+			}
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	sessions := session.NewRegistry()
+	devSess := session.New("356307042441013", serverConn)
+	devSess.RecordFrame(100, 2, 0x8e)
+	sessions.Register(devSess, session.DuplicateCloseOld)
+	client := newTestClient(t, live.NewHub(), sessions)
+
+	resp, err := client.ListSessions(context.Background(), &gatewayv1.ListSessionsRequest{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(resp.GetSessions()), 1)
+	sess := resp.GetSessions()[0]
+	assert.Equal(t, sess.GetImei(), "356307042441013")
+	assert.Equal(t, sess.GetRecordsReceived(), uint64(2))
+	assert.Equal(t, sess.GetCodecId(), uint32(0x8e))
+	assert.Assert(t, sess.GetLastPacketAt() != nil)
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestChecker(t *testing.T) {
+	tests := map[string]struct {
+		checks   map[string]Check
+		wantCode int
+		want     Report
+	}{
+		"no checks": {
+			wantCode: http.StatusOK,
+			want:     Report{Status: StatusOK},
+		},
+		"all ready": {
+			checks: map[string]Check{
+				"nats":  func(context.Context) error { return nil },
+				"avldb": func(context.Context) error { return nil },
+			},
+			wantCode: http.StatusOK,
+			want: Report{Status: StatusOK, Checks: map[string]CheckResult{
+				"nats":  {Status: StatusOK},
+				"avldb": {Status: StatusOK},
+			}},
+		},
+		"failed check": {
+			checks: map[string]Check{
+				"nats":  func(context.Context) error { return nil },
+				"avldb": func(context.Context) error { return errors.New("connection refused") },
+			},
+			wantCode: http.StatusServiceUnavailable,
+			want: Report{Status: StatusUnavailable, Checks: map[string]CheckResult{
+				"nats":  {Status: StatusOK},
+				"avldb": {Status: StatusUnavailable, Error: "connection refused"},
+			}},
+		},
+		"timed out check": {
+			checks: map[string]Check{
+				"avldb": func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			wantCode: http.StatusServiceUnavailable,
+			want: Report{Status: StatusUnavailable, Checks: map[string]CheckResult{
+				"avldb": {Status: StatusUnavailable, Error: context.DeadlineExceeded.Error()},
+			}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checker := NewChecker(time.Millisecond * 50)
+			for checkName, check := range test.checks {
+				checker.Add(checkName, check)
+			}
+			recorder := httptest.NewRecorder()
+			checker.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+			assert.Equal(t, recorder.Code, test.wantCode)
+			var report Report
+			assert.NilError(t, json.NewDecoder(recorder.Body).Decode(&report))
+			if len(report.Checks) == 0 {
+				report.Checks = nil
+			}
+			assert.DeepEqual(t, report, test.want)
+		})
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/json")
+}
@@ -0,0 +1,224 @@
+package live
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultBufferSize is the number of points buffered for a subscriber
+const DefaultBufferSize = 256
+
+var (
+	subscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "teltonika",
+		Name:      "live_subscribers",
+		Help:      "Subscribers of live decoded points.",
+	})
+	droppedPoints = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "live_dropped_points_total",
+		Help:      "Points dropped because a live subscriber buffer was full.",
+	})
+)
+
+// Filter selects points delivered to a subscriber
+type Filter func(point *pb.AVLData) bool
+
+// IMEIFilter selects points of imeis, all points are selected when imeis is empty
+func IMEIFilter(imeis ...string) Filter {
+	if len(imeis) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(imeis))
+	for _, imei := range imeis {
+		set[imei] = struct{}{}
+	}
+	return func(point *pb.AVLData) bool {
+		_, found := set[point.GetImei()]
+		return found
+	}
+}
+
+// BoundingBox is an area in degrees, points on its border are inside it
+type BoundingBox struct {
+	MinLongitude float64
+	MinLatitude  float64
+	MaxLongitude float64
+	MaxLatitude  float64
+}
+
+// Contains reports whether position of point is inside the box
+func (b BoundingBox) Contains(point *pb.AVLData) bool {
+	gps := point.GetGps()
+	if gps == nil {
+		return false
+	}
+	return gps.GetLongitude() >= b.MinLongitude && gps.GetLongitude() <= b.MaxLongitude &&
+		gps.GetLatitude() >= b.MinLatitude && gps.GetLatitude() <= b.MaxLatitude
+}
+
+// BoundingBoxFilter selects points inside box
+func BoundingBoxFilter(box BoundingBox) Filter {
+	return box.Contains
+}
+
+// AllFilters selects points selected by every filter, nil filters select all points
+func AllFilters(filters ...Filter) Filter {
+	var selected []Filter
+	for _, filter := range filters {
+		if filter != nil {
+			selected = append(selected, filter)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return func(point *pb.AVLData) bool {
+		for _, filter := range selected {
+			if !filter(point) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Hub fans decoded points out to live subscribers and keeps the newest point of every device.
+// It is a db.PointSink, so the server delivers points to it like to other sinks
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	lastPoints  map[string]*pb.AVLData
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+		lastPoints:  make(map[string]*pb.AVLData),
+	}
+}
+
+// Subscription receives points selected by its filter until it is closed
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	points  chan *pb.AVLData
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe returns a subscription buffering up to bufferSize points, points are dropped
+// while its buffer is full so slow subscribers never block the pipeline
+func (h *Hub) Subscribe(filter Filter, bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		points: make(chan *pb.AVLData, bufferSize),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub.points)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	subscribers.Inc()
+	return sub
+}
+
+// Points is closed when the subscription or the hub is closed
+func (s *Subscription) Points() <-chan *pb.AVLData {
+	return s.points
+}
+
+// Dropped returns the number of points dropped because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes from the hub
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.close()
+}
+
+// close must be called with hub lock held
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		if _, found := s.hub.subscribers[s]; found {
+			delete(s.hub.subscribers, s)
+			subscribers.Dec()
+		}
+		close(s.points)
+	})
+}
+
+func (s *Subscription) send(point *pb.AVLData) {
+	if s.filter != nil && !s.filter(point) {
+		return
+	}
+	select {
+	case s.points <- point:
+	default:
+		s.dropped.Add(1)
+		droppedPoints.Inc()
+	}
+}
+
+// SaveAvlPoints delivers points to subscribers without blocking
+func (h *Hub) SaveAvlPoints(_ context.Context, points []*pb.AVLData) error {
+	if len(points) == 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	newest := parser.NewestPoint(points)
+	if last, found := h.lastPoints[newest.GetImei()]; found {
+		// stored records uploaded after a coverage gap must not replace a newer point
+		newest = parser.NewestPoint([]*pb.AVLData{last, newest})
+	}
+	h.lastPoints[newest.GetImei()] = newest
+	for sub := range h.subscribers {
+		for _, point := range points {
+			sub.send(point)
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the number of open subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// LastPoint returns the newest point received from imei
+func (h *Hub) LastPoint(imei string) (*pb.AVLData, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	point, found := h.lastPoints[imei]
+	return point, found
+}
+
+// Close closes all subscriptions, later subscriptions are closed immediately
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		sub.close()
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package live
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	all := hub.Subscribe(nil, 0)
+	filtered := hub.Subscribe(IMEIFilter("356307042441013"), 1)
+
+	assert.NilError(t, hub.SaveAvlPoints(context.Background(), []*pb.AVLData{
+		{Imei: "356307042441013", Timestamp: "2023-09-27 07:33:20"},
+		{Imei: "356307042441013", Timestamp: "2023-09-27 07:33:30"},
+	}))
+	assert.NilError(t, hub.SaveAvlPoints(context.Background(), []*pb.AVLData{
+		{Imei: "352094087982671", Timestamp: "2023-09-27 07:33:25"},
+	}))
+
+	assert.Equal(t, len(all.Points()), 3)
+	assert.Equal(t, all.Dropped(), uint64(0))
+	// full buffer drops points instead of blocking
+	assert.Equal(t, len(filtered.Points()), 1)
+	assert.Equal(t, filtered.Dropped(), uint64(1))
+	point := <-filtered.Points()
+	assert.Equal(t, point.GetTimestamp(), "2023-09-27 07:33:20")
+
+	last, found := hub.LastPoint("356307042441013")
+	assert.Assert(t, found)
+	assert.Equal(t, last.GetTimestamp(), "2023-09-27 07:33:30")
+	_, found = hub.LastPoint("356478954125694")
+	assert.Assert(t, !found)
+
+	filtered.Close()
+	filtered.Close()
+	_, ok := <-filtered.Points()
+	assert.Assert(t, !ok)
+
+	// stored records of a later packet do not replace the newest point
+	assert.NilError(t, hub.SaveAvlPoints(context.Background(), []*pb.AVLData{
+		{Imei: "356307042441013", Timestamp: "2023-09-27 07:30:00"},
+	}))
+	last, _ = hub.LastPoint("356307042441013")
+	assert.Equal(t, last.GetTimestamp(), "2023-09-27 07:33:30")
+
+	assert.NilError(t, hub.Close())
+	for range all.Points() {
+	}
+	closed := hub.Subscribe(nil, 0)
+	_, ok = <-closed.Points()
+	assert.Assert(t, !ok)
+}
+
+func TestFilters(t *testing.T) {
+	box := BoundingBox{MinLongitude: 51, MinLatitude: 35, MaxLongitude: 52, MaxLatitude: 36}
+	tests := map[string]struct {
+		filter Filter
+		point  *pb.AVLData
+		want   bool
+	}{
+		"imei":            {filter: IMEIFilter("356307042441013"), point: &pb.AVLData{Imei: "356307042441013"}, want: true},
+		"other imei":      {filter: IMEIFilter("356307042441013"), point: &pb.AVLData{Imei: "352094087982671"}, want: false},
+		"inside box":      {filter: BoundingBoxFilter(box), point: &pb.AVLData{Gps: &pb.GPS{Longitude: 51.4, Latitude: 36}}, want: true},
+		"outside box":     {filter: BoundingBoxFilter(box), point: &pb.AVLData{Gps: &pb.GPS{Longitude: 52.1, Latitude: 35.7}}, want: false},
+		"without gps":     {filter: BoundingBoxFilter(box), point: &pb.AVLData{}, want: false},
+		"all match":       {filter: AllFilters(IMEIFilter("356307042441013"), nil, BoundingBoxFilter(box)), point: &pb.AVLData{Imei: "356307042441013", Gps: &pb.GPS{Longitude: 51.4, Latitude: 35.7}}, want: true},
+		"one not matched": {filter: AllFilters(IMEIFilter("356307042441013"), BoundingBoxFilter(box)), point: &pb.AVLData{Imei: "356307042441013"}, want: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.filter(test.point), test.want)
+		})
+	}
+	assert.Assert(t, AllFilters(nil, IMEIFilter()) == nil)
+}
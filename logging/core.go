@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// imeiCore filters entries by controller level, entries below level pass when
+// their imei field, given to With or to the log call, is in debug mode
+type imeiCore struct {
+	zapcore.Core
+	controller *Controller
+	// debug is set when imei field of a With call is in debug mode
+	debug bool
+}
+
+// NewCore wraps core with level and per-IMEI debug filtering of controller
+func NewCore(core zapcore.Core, controller *Controller) zapcore.Core {
+	return &imeiCore{Core: core, controller: controller}
+}
+
+func (c *imeiCore) Enabled(level zapcore.Level) bool {
+	return c.controller.level.Enabled(level) || c.debug || c.controller.anyDebug()
+}
+
+func (c *imeiCore) With(fields []zapcore.Field) zapcore.Core {
+	return &imeiCore{
+		Core:       c.Core.With(fields),
+		controller: c.controller,
+		debug:      c.debug || c.debugFields(fields),
+	}
+}
+
+func (c *imeiCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.controller.level.Enabled(entry.Level) || c.debug {
+		return c.Core.Check(entry, checked)
+	}
+	if c.controller.anyDebug() {
+		// fields of the log call are only known on write
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *imeiCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !c.controller.level.Enabled(entry.Level) && !c.debug && !c.debugFields(fields) {
+		return nil
+	}
+	return c.Core.Write(entry, fields)
+}
+
+func (c *imeiCore) debugFields(fields []zapcore.Field) bool {
+	for _, field := range fields {
+		if field.Key == IMEIKey && field.Type == zapcore.StringType && c.controller.Debug(field.String) {
+			return true
+		}
+	}
+	return false
+}
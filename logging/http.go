@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// PathPrefix is the http path logging controls are served on
+const PathPrefix = "/api/v1/logging"
+
+// debugPath is appended to PathPrefix for per-IMEI debug mode
+const debugPath = "/debug/"
+
+// State is the json body of logging controls
+type State struct {
+	Level      string   `json:"level"`
+	DebugIMEIs []string `json:"debug_imeis"`
+}
+
+// ServeHTTP handles GET and PUT /api/v1/logging to read and change level,
+// and PUT and DELETE /api/v1/logging/debug/{imei} to switch debug mode of a device
+func (c *Controller) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, PathPrefix), "/")
+	switch {
+	case path == "":
+		c.serveLevel(w, req)
+	case strings.HasPrefix(path, debugPath):
+		c.serveDebug(w, req, strings.TrimPrefix(path, debugPath))
+	default:
+		writeHTTPError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (c *Controller) serveLevel(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var state State
+		if err := json.NewDecoder(req.Body).Decode(&state); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		level, err := zapcore.ParseLevel(state.Level)
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		c.SetLevel(level)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeJSON(w, c.state())
+}
+
+func (c *Controller) serveDebug(w http.ResponseWriter, req *http.Request, imei string) {
+	if imei == "" || strings.Contains(imei, "/") {
+		writeHTTPError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	switch req.Method {
+	case http.MethodPut:
+		c.SetDebug(imei, true)
+	case http.MethodDelete:
+		c.SetDebug(imei, false)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeJSON(w, c.state())
+}
+
+func (c *Controller) state() *State {
+	return &State{
+		Level:      c.Level().String(),
+		DebugIMEIs: c.DebugIMEIs(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	ErrInvalidFormat = errors.New("log format must be json or console")
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+
+	// IMEIKey is the log field key matched by per-IMEI debug mode
+	IMEIKey = "imei"
+)
+
+type Options struct {
+	Level  string
+	Format string
+	// SamplingInitial and SamplingThereafter sample repeated entries per second, zero disables sampling
+	SamplingInitial    int
+	SamplingThereafter int
+	// DebugIMEIs are logged at debug level regardless of Level
+	DebugIMEIs []string
+}
+
+// DefaultOptions match zap production logger
+var DefaultOptions = Options{
+	Level:              "info",
+	Format:             FormatJSON,
+	SamplingInitial:    100,
+	SamplingThereafter: 100,
+}
+
+func (o *Options) Validate() error {
+	if o.Format != FormatJSON && o.Format != FormatConsole {
+		return ErrInvalidFormat
+	}
+	_, err := zapcore.ParseLevel(o.Level)
+	return err
+}
+
+// Controller changes log level and per-IMEI debug mode at runtime
+type Controller struct {
+	level zap.AtomicLevel
+	mu    sync.RWMutex
+	imeis map[string]struct{}
+}
+
+// Level returns current log level
+func (c *Controller) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+// SetLevel changes log level
+func (c *Controller) SetLevel(level zapcore.Level) {
+	c.level.SetLevel(level)
+}
+
+// SetDebug switches debug logging of imei on or off
+func (c *Controller) SetDebug(imei string, enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enabled {
+		c.imeis[imei] = struct{}{}
+		return
+	}
+	delete(c.imeis, imei)
+}
+
+// Debug reports whether imei is logged at debug level
+func (c *Controller) Debug(imei string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, found := c.imeis[imei]
+	return found
+}
+
+// DebugIMEIs returns sorted IMEIs in debug mode
+func (c *Controller) DebugIMEIs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	imeis := make([]string, 0, len(c.imeis))
+	for imei := range c.imeis {
+		imeis = append(imeis, imei)
+	}
+	sort.Strings(imeis)
+	return imeis
+}
+
+func (c *Controller) anyDebug() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.imeis) > 0
+}
+
+// New creates a logger writing to stderr and its controller
+func New(opts Options) (*zap.Logger, *Controller, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+	level, _ := zapcore.ParseLevel(opts.Level)
+	controller := &Controller{
+		level: zap.NewAtomicLevelAt(level),
+		imeis: make(map[string]struct{}),
+	}
+	for _, imei := range opts.DebugIMEIs {
+		controller.SetDebug(imei, true)
+	}
+	encoderConfig := zap.NewProductionEncoderConfig()
+	var encoder zapcore.Encoder
+	if opts.Format == FormatConsole {
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
+	// level is checked by imeiCore, so the output core accepts every level
+	var core zapcore.Core = zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), zapcore.DebugLevel)
+	if opts.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.SamplingInitial, opts.SamplingThereafter)
+	}
+	logger := zap.New(NewCore(core, controller),
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return logger, controller, nil
+}
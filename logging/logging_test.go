@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gotest.tools/v3/assert"
+)
+
+func newTestLogger(level zapcore.Level, debugIMEIs ...string) (*zap.Logger, *Controller, *observer.ObservedLogs) {
+	controller := &Controller{level: zap.NewAtomicLevelAt(level), imeis: make(map[string]struct{})}
+	for _, imei := range debugIMEIs {
+		controller.SetDebug(imei, true)
+	}
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(NewCore(core, controller)), controller, logs
+}
+
+func TestIMEIDebug(t *testing.T) {
+	const debugIMEI, otherIMEI = "356307042441013", "352094087982671"
+	tests := map[string]struct {
+		log      func(logger *zap.Logger)
+		wantLogs int
+	}{
+		"info of any device": {
+			log:      func(logger *zap.Logger) { logger.Info("connected", zap.String(IMEIKey, otherIMEI)) },
+			wantLogs: 1,
+		},
+		"debug of other device": {
+			log: func(logger *zap.Logger) { logger.Debug("new packet", zap.String(IMEIKey, otherIMEI)) },
+		},
+		"debug without imei": {
+			log: func(logger *zap.Logger) { logger.Debug("set read deadline failed") },
+		},
+		"debug of debug device": {
+			log:      func(logger *zap.Logger) { logger.Debug("new packet", zap.String(IMEIKey, debugIMEI)) },
+			wantLogs: 1,
+		},
+		"debug of debug device logger": {
+			log: func(logger *zap.Logger) {
+				deviceLogger := logger.With(zap.String(IMEIKey, debugIMEI))
+				deviceLogger.Debug("new packet")
+				deviceLogger.Debug("decode io elements")
+			},
+			wantLogs: 2,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			logger, _, logs := newTestLogger(zapcore.InfoLevel, debugIMEI)
+			test.log(logger)
+			assert.Equal(t, logs.Len(), test.wantLogs)
+		})
+	}
+}
+
+func TestControllerRuntimeChanges(t *testing.T) {
+	const imei = "356307042441013"
+	logger, controller, logs := newTestLogger(zapcore.InfoLevel)
+	logger.Debug("new packet", zap.String(IMEIKey, imei))
+	assert.Equal(t, logs.Len(), 0)
+
+	controller.SetDebug(imei, true)
+	logger.Debug("new packet", zap.String(IMEIKey, imei))
+	assert.Equal(t, logs.Len(), 1)
+	controller.SetDebug(imei, false)
+	logger.Debug("new packet", zap.String(IMEIKey, imei))
+	assert.Equal(t, logs.Len(), 1)
+
+	controller.SetLevel(zapcore.WarnLevel)
+	logger.Info("connected")
+	assert.Equal(t, logs.Len(), 1)
+	controller.SetLevel(zapcore.DebugLevel)
+	logger.Debug("set read deadline failed")
+	assert.Equal(t, logs.Len(), 2)
+}
+
+func TestControllerHTTP(t *testing.T) {
+	_, controller, _ := newTestLogger(zapcore.InfoLevel)
+	tests := []struct {
+		method, path, body string
+		wantCode           int
+		want               State
+	}{
+		{method: http.MethodGet, path: PathPrefix, wantCode: http.StatusOK, want: State{Level: "info", DebugIMEIs: []string{}}},
+		{method: http.MethodPut, path: PathPrefix, body: `{"level":"warn"}`, wantCode: http.StatusOK, want: State{Level: "warn", DebugIMEIs: []string{}}},
+		{method: http.MethodPut, path: PathPrefix, body: `{"level":"loud"}`, wantCode: http.StatusBadRequest},
+		{method: http.MethodPut, path: PathPrefix + "/debug/356307042441013", wantCode: http.StatusOK, want: State{Level: "warn", DebugIMEIs: []string{"356307042441013"}}},
+		{method: http.MethodDelete, path: PathPrefix + "/debug/356307042441013", wantCode: http.StatusOK, want: State{Level: "warn", DebugIMEIs: []string{}}},
+		{method: http.MethodPost, path: PathPrefix, wantCode: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: PathPrefix + "/other", wantCode: http.StatusNotFound},
+	}
+	// steps share the controller, so they run in order
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		controller.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		assert.Equal(t, recorder.Code, test.wantCode, "%s %s", test.method, test.path)
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		var state State
+		assert.NilError(t, json.NewDecoder(recorder.Body).Decode(&state))
+		assert.DeepEqual(t, state, test.want)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := map[string]struct {
+		opts    Options
+		errWant error
+	}{
+		"default":        {opts: DefaultOptions},
+		"console":        {opts: Options{Level: "debug", Format: FormatConsole}},
+		"invalid format": {opts: Options{Level: "info", Format: "xml"}, errWant: ErrInvalidFormat},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.opts.Validate()
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+	invalidLevel := Options{Level: "loud", Format: FormatJSON}
+	assert.Assert(t, invalidLevel.Validate() != nil)
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidCommandResponse = errors.New("invalid codec 12 response")
+	ErrCommandTooLong         = errors.New("command is too long")
+)
+
+const (
+	// CodecCommand is codec 12, used for GPRS commands and their responses
+	CodecCommand = 0x0c
+
+	commandTypeRequest  = 0x05
+	commandTypeResponse = 0x06
+
+	// MaxCommandLength is the longest command text sent to a device
+	MaxCommandLength = 512
+
+	// CommandGetVer asks device for its firmware version, answered like "Ver:03.27.07_04 GPS:AXN_5.10 Hw:FMB920"
+	CommandGetVer = "getver"
+)
+
+// EncodeCommand encodes command as a codec 12 tcp frame
+func EncodeCommand(command string) ([]byte, error) {
+	if len(command) > MaxCommandLength {
+		return nil, ErrCommandTooLong
+	}
+	return encodeCommandFrame(commandTypeRequest, command), nil
+}
+
+// DecodeCommandResponse decodes response text of a codec 12 tcp frame read by ReadFrame
+func DecodeCommandResponse(frame []byte) (string, error) {
+	// codec id, quantity, type and response size
+	const responseHeaderLen = 7
+	if len(frame) < frameHeaderLen+responseHeaderLen+1+frameCRCLen {
+		return "", fmt.Errorf("%w: frame is truncated", ErrInvalidCommandResponse)
+	}
+	if !VerifyCRC(frame) {
+		return "", ErrCheckCRC
+	}
+	data := frame[frameHeaderLen : len(frame)-frameCRCLen]
+	switch {
+	case data[0] != CodecCommand:
+		return "", ErrUnsupportedCodec
+	case data[2] != commandTypeResponse:
+		return "", fmt.Errorf("%w: unexpected type 0x%02x", ErrInvalidCommandResponse, data[2])
+	}
+	size := int(binary.BigEndian.Uint32(data[3:7]))
+	if responseHeaderLen+size+1 != len(data) {
+		return "", fmt.Errorf("%w: response size does not match frame", ErrInvalidCommandResponse)
+	}
+	return string(data[responseHeaderLen : responseHeaderLen+size]), nil
+}
+
+// EncodeCommandResponse encodes response as a codec 12 tcp frame like devices send it
+func EncodeCommandResponse(response string) []byte {
+	return encodeCommandFrame(commandTypeResponse, response)
+}
+
+func encodeCommandFrame(commandType byte, text string) []byte {
+	data := []byte{CodecCommand, 1, commandType}
+	data = binary.BigEndian.AppendUint32(data, uint32(len(text)))
+	data = append(data, text...)
+	data = append(data, 1)
+	return encodeFrame(data)
+}
+
+// FirmwareVersion returns the firmware version of a getver response
+func FirmwareVersion(response string) (string, bool) {
+	for _, field := range strings.Fields(response) {
+		if version, found := strings.CutPrefix(field, "Ver:"); found && version != "" {
+			return version, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	frame, err := EncodeCommand("getinfo")
+	assert.NilError(t, err)
+	// getinfo example of teltonika codec 12 documentation
+	assert.Equal(t, strings.ToUpper(hex.EncodeToString(frame)), "000000000000000F0C010500000007676574696E666F0100004312")
+
+	_, err = EncodeCommand(strings.Repeat("a", MaxCommandLength+1))
+	assert.ErrorIs(t, err, ErrCommandTooLong)
+}
+
+func TestDecodeCommandResponse(t *testing.T) {
+	tests := map[string]struct {
+		dataString string
+		errWant    error
+		want       string
+	}{
+		"getinfo response": {
+			dataString: `00000000000000900C010600000088494E493A323031392F372F323220373A3232205254433A323031392F372F323220373A3533205253543A32204552523A312053523A302042523A302043463A302046473A3020464C3A302054553A302F302055543A3020534D533A30204E4F4750533A303A3330204750533A31205341543A302052533A332052463A36352053463A31204D443A30010000C78F`,
+			want:       "INI:2019/7/22 7:22 RTC:2019/7/22 7:53 RST:2 ERR:1 SR:0 BR:0 CF:0 FG:0 FL:0 TU:0/0 UT:0 SMS:0 NOGPS:0:30 GPS:1 SAT:0 RS:3 RF:65 SF:1 MD:0",
+		},
+		"invalid crc": {
+			dataString: `00000000000000900C010600000088494E493A323031392F372F323220373A3232205254433A323031392F372F323220373A3533205253543A32204552523A312053523A302042523A302043463A302046473A3020464C3A302054553A302F302055543A3020534D533A30204E4F4750533A303A3330204750533A31205341543A302052533A332052463A36352053463A31204D443A30010000C78E`,
+			errWant:    ErrCheckCRC,
+		},
+		"request instead of response": {
+			dataString: `000000000000000F0C010500000007676574696E666F0100004312`,
+			errWant:    ErrInvalidCommandResponse,
+		},
+		"truncated": {
+			dataString: `00000000000000030C0106`,
+			errWant:    ErrInvalidCommandResponse,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame, err := hex.DecodeString(test.dataString)
+			assert.NilError(t, err)
+			response, err := DecodeCommandResponse(frame)
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, response, test.want)
+		})
+	}
+	response, err := DecodeCommandResponse(EncodeCommandResponse("Param ID:2001 New Text:internet"))
+	assert.NilError(t, err)
+	assert.Equal(t, response, "Param ID:2001 New Text:internet")
+}
+
+func TestFirmwareVersion(t *testing.T) {
+	tests := map[string]struct {
+		response  string
+		want      string
+		wantFound bool
+	}{
+		"getver":     {response: "Ver:03.27.07_04 GPS:AXN_5.10_3333 Hw:FMB920 Mod:13 IMEI:352093086403655", want: "03.27.07_04", wantFound: true},
+		"no version": {response: "RTC:2023/9/27 7:33 Init:2023/9/27 7:30"},
+		"empty":      {response: "Ver: GPS:AXN_5.10_3333"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			version, found := FirmwareVersion(test.response)
+			assert.Equal(t, found, test.wantFound)
+			assert.Equal(t, version, test.want)
+		})
+	}
+}
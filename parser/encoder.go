@@ -45,12 +45,13 @@ func MakeCodec8Packet(points []*AVLData) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	data = binary.BigEndian.AppendUint32(data, uint32(len(avlDataBytes))+1)
+	// data field length covers codec id, both number of data bytes and avl data
+	data = binary.BigEndian.AppendUint32(data, uint32(len(avlDataBytes))+3)
 	data = append(data, 0x8e)
 	data = append(data, uint8(len(points)))
 	data = append(data, avlDataBytes...)
 	data = append(data, uint8(len(points)))
-	data = binary.BigEndian.AppendUint32(data, 0) //crc16
+	data = binary.BigEndian.AppendUint32(data, uint32(calculateCRC16(data[8:]))) //crc16
 	return data, nil
 }
 
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrFrameTooLarge = errors.New("frame data length exceeds limit")
+)
+
+const (
+	// frameHeaderLen is preamble (4 bytes) + data field length (4 bytes)
+	frameHeaderLen = 8
+	// frameCRCLen is the CRC-16 trailer, sent as 4 bytes
+	frameCRCLen = 4
+	// MaxFrameDataLength is the biggest data field accepted from a device
+	MaxFrameDataLength = 64 * 1024
+)
+
+// ReadFrame reads exactly one tcp AVL frame (preamble, data length, data field and CRC) from reader
+func ReadFrame(reader io.Reader) ([]byte, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[:4]) != 0 {
+		return nil, ErrInvalidPreamble
+	}
+	dataLength := binary.BigEndian.Uint32(header[4:])
+	if dataLength > MaxFrameDataLength {
+		return nil, ErrFrameTooLarge
+	}
+	frame := make([]byte, frameHeaderLen+int(dataLength)+frameCRCLen)
+	copy(frame, header)
+	if _, err := io.ReadFull(reader, frame[frameHeaderLen:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// FrameCodecID returns codec id of a tcp AVL frame
+func FrameCodecID(frame []byte) uint8 {
+	if len(frame) <= frameHeaderLen {
+		return 0
+	}
+	return frame[frameHeaderLen]
+}
+
+// FrameRecordCount returns number of records declared in a tcp AVL frame header
+func FrameRecordCount(frame []byte) uint8 {
+	if len(frame) <= frameHeaderLen+1 {
+		return 0
+	}
+	return frame[frameHeaderLen+1]
+}
+
+// VerifyCRC checks CRC-16/IBM of the data field against the frame trailer
+func VerifyCRC(frame []byte) bool {
+	if len(frame) < frameHeaderLen+frameCRCLen {
+		return false
+	}
+	crc := binary.BigEndian.Uint32(frame[len(frame)-frameCRCLen:])
+	return uint32(calculateCRC16(frame[frameHeaderLen:len(frame)-frameCRCLen])) == crc
+}
+
+// encodeFrame wraps data field in a tcp frame with preamble, data length and CRC
+func encodeFrame(data []byte) []byte {
+	frame := make([]byte, 0, frameHeaderLen+len(data)+frameCRCLen)
+	frame = binary.BigEndian.AppendUint32(frame, 0)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+	return binary.BigEndian.AppendUint32(frame, uint32(calculateCRC16(data)))
+}
+
+// CodecName returns teltonika name of codecID, unknown codecs are named by their hex value
+func CodecName(codecID uint8) string {
+	switch codecID {
+	case 0x08:
+		return "8"
+	case 0x8e:
+		return "8E"
+	case 0x10:
+		return "16"
+	case 0x0c:
+		return "12"
+	case 0x0d:
+		return "13"
+	case 0x0e:
+		return "14"
+	}
+	return fmt.Sprintf("0x%02x", codecID)
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := map[string]struct {
+		errWant    error
+		dataString string
+		frameLen   int
+		codecID    uint8
+		records    uint8
+		crcValid   bool
+	}{
+		"success": {
+			dataString: `000000000000004A8E010000016B412CEE000100000000000000000000000000000000010005000100010100010011001D00010010015E2C880002000B000000003544C87A000E000000001DD7E06A00000100002994`,
+			frameLen:   86,
+			codecID:    0x8e,
+			records:    1,
+			crcValid:   true,
+		},
+		"trailing data is not consumed": {
+			dataString: `000000000000004A8E010000016B412CEE000100000000000000000000000000000000010005000100010100010011001D00010010015E2C880002000B000000003544C87A000E000000001DD7E06A00000100002994000000000000`,
+			frameLen:   86,
+			codecID:    0x8e,
+			records:    1,
+			crcValid:   true,
+		},
+		"invalid crc": {
+			dataString: `000000000000004A8E010000016B412CEE000100000000000000000000000000000000010005000100010100010011001D00010010015E2C880002000B000000003544C87A000E000000001DD7E06A00000100002995`,
+			frameLen:   86,
+			codecID:    0x8e,
+			records:    1,
+			crcValid:   false,
+		},
+		"invalid preamble": {
+			dataString: `000000010000004A8E01`,
+			errWant:    ErrInvalidPreamble,
+		},
+		"too large": {
+			dataString: `00000000FFFFFFFF8E01`,
+			errWant:    ErrFrameTooLarge,
+		},
+		"truncated": {
+			dataString: `000000000000004A8E010000016B412CEE`,
+			errWant:    io.ErrUnexpectedEOF,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dataBytes, err := hex.DecodeString(test.dataString)
+			assert.NilError(t, err)
+			frame, err := ReadFrame(bytes.NewReader(dataBytes))
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, len(frame), test.frameLen)
+			assert.Equal(t, FrameCodecID(frame), test.codecID)
+			assert.Equal(t, FrameRecordCount(frame), test.records)
+			assert.Equal(t, VerifyCRC(frame), test.crcValid)
+		})
+	}
+}
+
+func TestReadFrameEncodedPacket(t *testing.T) {
+	packet, err := MakeCodec8Packet([]*AVLData{
+		{Priority: PriorityHigh, Longitude: 25.451, Latitude: 31.654, Speed: 80},
+		{Priority: PriorityLow, Longitude: 28.451, Latitude: 16.654, Speed: 12},
+	})
+	assert.NilError(t, err)
+	frame, err := ReadFrame(bytes.NewReader(packet))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, frame, packet)
+	assert.Assert(t, VerifyCRC(frame))
+	assert.Equal(t, FrameRecordCount(frame), uint8(2))
+}
@@ -0,0 +1,18 @@
+package parser
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+var logger atomic.Pointer[zap.Logger]
+
+func init() {
+	logger.Store(zap.NewNop())
+}
+
+// SetLogger sets logger of parser diagnostics, nothing is logged by default
+func SetLogger(l *zap.Logger) {
+	logger.Store(l)
+}
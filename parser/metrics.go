@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// io element decoders, named by value size
+const (
+	decoderOneByte   = "1byte"
+	decoderTwoByte   = "2byte"
+	decoderFourByte  = "4byte"
+	decoderEightByte = "8byte"
+)
+
+// unknownIOElements counts io elements decoded without a known id mapping
+var unknownIOElements = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "teltonika",
+	Name:      "unknown_io_elements_total",
+	Help:      "IO elements whose id is not known to the decoder, by decoder.",
+}, []string{"decoder"})
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	pb "github.com/irisco88/protos/gen/device/v1"
+	"go.uber.org/zap"
 )
 
 var (
@@ -19,17 +20,27 @@ var (
 	ErrInvalidHeader       = errors.New("parse header failed")
 	ErrCheckCRC            = errors.New("CRC check failed")
 	ErrUnsupportedCodec    = errors.New("codec not supported")
+	ErrTruncatedPacket     = errors.New("packet is truncated")
 )
 
 const PRECISION = 10000000.0
 
+// Version tags every decoded point, bump it whenever decoding output changes
+const Version = "1.1.0"
+
 type Header struct {
 	DataLength   uint32
 	CodecID      uint8
 	NumberOfData uint8
 }
 
+// headerLen is preamble, data field length, codec id and number of data
+const headerLen = 10
+
 func ParseHeader(reader *bytes.Buffer) (*Header, error) {
+	if reader.Len() < headerLen {
+		return nil, ErrTruncatedPacket
+	}
 	header := &Header{}
 	preamble := binary.BigEndian.Uint32(reader.Next(4))
 	if preamble != uint32(0) {
@@ -41,30 +52,39 @@ func ParseHeader(reader *bytes.Buffer) (*Header, error) {
 	return header, nil
 }
 
+// next reads n bytes of reader, frames are read with their exact length so a malformed
+// frame may end before the records it declares
+func next(reader *bytes.Buffer, n int) ([]byte, error) {
+	if reader.Len() < n {
+		return nil, ErrTruncatedPacket
+	}
+	return reader.Next(n), nil
+}
+
 func ParsePacket(data []byte, imei string) ([]*pb.AVLData, error) {
-	reader := bytes.NewBuffer(data)
+	if !VerifyCRC(data) {
+		return nil, ErrCheckCRC
+	}
+	// CRC trailer is verified, records must end before it
+	reader := bytes.NewBuffer(data[:len(data)-frameCRCLen])
 	header, err := ParseHeader(reader)
 	if err != nil {
 		return nil, ErrInvalidHeader
 	}
-	var points []*pb.AVLData
-	if header.CodecID == 0x8e {
-		points, err = parseCodec8EPacket(reader, header, imei)
-		if err != nil {
-			return nil, err
-		}
-	} else {
+	if header.CodecID != 0x8e {
 		return nil, ErrUnsupportedCodec
 	}
-	// Once finished with the records we read the Record Number and the CRC
-	if reader.Next(1)[0] != header.NumberOfData {
-		return nil, ErrInvalidNumberOfData
+	points, err := parseCodec8EPacket(reader, header, imei)
+	if err != nil {
+		return nil, err
 	}
-	crc := binary.BigEndian.Uint32(reader.Next(4))
-	calculatedCRC := calculateCRC16(data)
-	if uint32(calculatedCRC) != crc {
-		//TODO check crc
-		//return nil, ErrCheckCRC
+	// Once finished with the records we read the Record Number, CRC is verified above
+	numberOfData, err := next(reader, 1)
+	if err != nil {
+		return nil, err
+	}
+	if numberOfData[0] != header.NumberOfData {
+		return nil, ErrInvalidNumberOfData
 	}
 	return points, nil
 }
@@ -74,36 +94,40 @@ func convertToDate(epochTimestamp int64) string {
 	}
 	// Convert epoch timestamp to time.Time
 	timeValue := time.Unix(0, epochTimestamp*int64(time.Millisecond))
-	tehranLocation, err := time.LoadLocation("Asia/Tehran")
-	if err != nil {
-		fmt.Println("Error loading Tehran location:", err)
-	}
-	// Convert to Tehran local time
-	tehranLocalTime := timeValue.In(tehranLocation)
-	// Format time in a desired layout
-	dateString := tehranLocalTime.Format("2006-01-02 15:04:05")
-	return dateString
+	return FormatTimestamp(timeValue)
 }
+
+// recordHeaderLen is timestamp (8), priority (1), gps element (15) and event io id (2) of a record
+const recordHeaderLen = 26
+
+// ioValueLen is value size of io elements by stage of codec 8E
+var ioValueLen = [...]int{1: 1, 2: 2, 3: 4, 4: 8}
+
 func parseCodec8EPacket(reader *bytes.Buffer, header *Header, imei string) ([]*pb.AVLData, error) {
 	points := make([]*pb.AVLData, header.NumberOfData)
 	for i := uint8(0); i < header.NumberOfData; i++ {
-		timestamps := binary.BigEndian.Uint64(reader.Next(8))
+		// timestamp, priority, gps element and event io id
+		record, err := next(reader, recordHeaderLen)
+		if err != nil {
+			return nil, err
+		}
+		timestamps := binary.BigEndian.Uint64(record[0:8])
 		timestamp := convertToDate(int64(timestamps))
-		priority := reader.Next(1)[0]
+		priority := record[8]
 		// GPS Element
-		longitude := int32(binary.BigEndian.Uint32(reader.Next(4)))
+		longitude := int32(binary.BigEndian.Uint32(record[9:13]))
 		if longitude>>31 == 1 {
 			longitude *= -1
 		}
-		latitude := int32(binary.BigEndian.Uint32(reader.Next(4)))
+		latitude := int32(binary.BigEndian.Uint32(record[13:17]))
 		if latitude>>31 == 1 {
 			latitude *= -1
 		}
-		altitude := int32(binary.BigEndian.Uint16(reader.Next(2)))
-		angle := int32(binary.BigEndian.Uint16(reader.Next(2)))
-		Satellites := int32(reader.Next(1)[0])
-		speed := int32(binary.BigEndian.Uint16(reader.Next(2)))
-		eventID := binary.BigEndian.Uint16(reader.Next(2))
+		altitude := int32(binary.BigEndian.Uint16(record[17:19]))
+		angle := int32(binary.BigEndian.Uint16(record[19:21]))
+		Satellites := int32(record[21])
+		speed := int32(binary.BigEndian.Uint16(record[22:24]))
+		eventID := binary.BigEndian.Uint16(record[24:26])
 		points[i] = &pb.AVLData{
 			Imei:      imei,
 			Timestamp: timestamp,
@@ -120,7 +144,7 @@ func parseCodec8EPacket(reader *bytes.Buffer, header *Header, imei string) ([]*p
 		}
 		elements, err := parseCodec8eIOElements(reader)
 		if err != nil {
-			return nil, fmt.Errorf("parse io elements failed:%v", err)
+			return nil, fmt.Errorf("parse io elements failed:%w", err)
 		}
 		points[i].IoElements = elements
 	}
@@ -128,14 +152,30 @@ func parseCodec8EPacket(reader *bytes.Buffer, header *Header, imei string) ([]*p
 }
 func parseCodec8eIOElements(reader *bytes.Buffer) (elements []*pb.IOElement, err error) {
 	//total id (N of Total ID)
-	totalElements := binary.BigEndian.Uint16(reader.Next(2))
-	fmt.Println(totalElements)
+	total, err := next(reader, 2)
+	if err != nil {
+		return nil, err
+	}
+	totalElements := binary.BigEndian.Uint16(total)
+	logger.Load().Debug("decode io elements", zap.Uint16("count", totalElements))
 	//n1 , n2 , n4 , n8
 	for stage := 1; stage <= 4; stage++ {
 		//total id in this stage  (N 1|2|4|8 of One Byte Io )
-		stageElements := binary.BigEndian.Uint16(reader.Next(2))
+		count, err := next(reader, 2)
+		if err != nil {
+			return nil, err
+		}
+		stageElements := binary.BigEndian.Uint16(count)
 		for elementIndex := uint16(0); elementIndex < stageElements; elementIndex++ {
-			elementID := binary.BigEndian.Uint16(reader.Next(2))
+			id, err := next(reader, 2)
+			if err != nil {
+				return nil, err
+			}
+			elementID := binary.BigEndian.Uint16(id)
+			// value readers below expect the whole value in reader
+			if reader.Len() < ioValueLen[stage] {
+				return nil, ErrTruncatedPacket
+			}
 			switch stage {
 			case 1: // One byte IO Elements
 				elementValue := parseNOneValue(reader, elementID)
@@ -153,35 +193,69 @@ func parseCodec8eIOElements(reader *bytes.Buffer) (elements []*pb.IOElement, err
 			}
 		}
 	}
-	reader.Next(2) //nx
+	//nx
+	if _, err := next(reader, 2); err != nil {
+		return nil, err
+	}
 	return elements, nil
 }
 
+// oneByteIONames are names of known one byte io elements by io id
+var oneByteIONames = map[uint16]string{
+	1:   "DigitalInput1",
+	2:   "DigitalInput2",
+	21:  "GSMSignal",
+	144: "SDStatus",
+	179: "DigitalOutput1",
+	180: "DigitalOutput2",
+	239: "Ignition",
+	247: "CrashDetection",
+	255: "OverSpeeding",
+}
+
+// twoByteIONames are names of known two byte io elements by io id
+var twoByteIONames = map[uint16]string{
+	9:   "AnalogInput1",
+	10:  "AnalogInput2",
+	11:  "AnalogInput3",
+	66:  "ExternalVoltage",
+	67:  "BatteryVoltage",
+	70:  "PCBTemperature",
+	245: "AnalogInput4",
+}
+
+// ioElementIDs maps names given by one and two byte decoders back to io ids
+var ioElementIDs = func() map[string]uint16 {
+	ids := make(map[string]uint16, len(oneByteIONames)+len(twoByteIONames))
+	for id, name := range oneByteIONames {
+		ids[name] = id
+	}
+	for id, name := range twoByteIONames {
+		ids[name] = id
+	}
+	return ids
+}()
+
+// IOElementID returns io id of an element named by the decoder, numeric names are raw io ids.
+// Elements derived from eight byte values have no io id of their own.
+func IOElementID(name string) (uint16, bool) {
+	if id, found := ioElementIDs[name]; found {
+		return id, true
+	}
+	id, err := strconv.ParseUint(name, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(id), true
+}
+
 func parseNOneValue(reader *bytes.Buffer, elementId uint16) (values *pb.IOElement) {
-	var elementName string
 	var elementIntValue float64
 	elementIntValue = float64(int64(reader.Next(1)[0]))
 	var value pb.IOElement
-	switch elementId {
-	case 1:
-		elementName = "DigitalInput1"
-	case 2:
-		elementName = "DigitalInput2"
-	case 21:
-		elementName = "GSMSignal"
-	case 144:
-		elementName = "SDStatus"
-	case 179:
-		elementName = "DigitalOutput1"
-	case 180:
-		elementName = "DigitalOutput2"
-	case 239:
-		elementName = "Ignition"
-	case 247:
-		elementName = "CrashDetection"
-	case 255:
-		elementName = "OverSpeeding"
-	default:
+	elementName, found := oneByteIONames[elementId]
+	if !found {
+		unknownIOElements.WithLabelValues(decoderOneByte).Inc()
 		elementName = strconv.Itoa(int(elementId))
 	}
 	value.ElementName = elementName
@@ -190,26 +264,12 @@ func parseNOneValue(reader *bytes.Buffer, elementId uint16) (values *pb.IOElemen
 	return &value
 }
 func parseNTowValue(reader *bytes.Buffer, elementId uint16) (values *pb.IOElement) {
-	var elementName string
 	var value pb.IOElement
 	var elementIntValue float64
 	elementIntValue = float64(int64(binary.BigEndian.Uint16(reader.Next(2))))
-	switch elementId {
-	case 9:
-		elementName = "AnalogInput1"
-	case 10:
-		elementName = "AnalogInput2"
-	case 11:
-		elementName = "AnalogInput3"
-	case 66:
-		elementName = "ExternalVoltage"
-	case 67:
-		elementName = "BatteryVoltage"
-	case 70:
-		elementName = "PCBTemperature"
-	case 245:
-		elementName = "AnalogInput4"
-	default:
+	elementName, found := twoByteIONames[elementId]
+	if !found {
+		unknownIOElements.WithLabelValues(decoderTwoByte).Inc()
 		elementName = strconv.Itoa(int(elementId))
 	}
 	value.ElementName = elementName
@@ -224,6 +284,8 @@ func parseNFourValue(reader *bytes.Buffer, elementId uint16) (values *pb.IOEleme
 	var value pb.IOElement
 	elementIntValue = int64(binary.BigEndian.Uint32(reader.Next(4)))
 	elementIntValues = float64(elementIntValue)
+	// four byte elements have no id mapping
+	unknownIOElements.WithLabelValues(decoderFourByte).Inc()
 	elementName = strconv.Itoa(int(elementId))
 
 	value.ElementName = elementName
@@ -905,6 +967,7 @@ func parseNEightValue(reader *bytes.Buffer, elementId uint16) (value []*pb.IOEle
 			//values = append(values, &elementItem)
 		}
 	default:
+		unknownIOElements.WithLabelValues(decoderEightByte).Inc()
 		var elementItem pb.IOElement
 		elementItem.ElementName = strconv.Itoa(int(elementId))
 		elementItem.ElementValue = 999
@@ -115,3 +115,50 @@ func TestParsePacket(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePacketMalformed(t *testing.T) {
+	frame, err := MakeCodec8Packet([]*AVLData{{Priority: PriorityHigh, Longitude: 51.4, Latitude: 35.7}})
+	assert.NilError(t, err)
+	// frames are rewrapped with a valid CRC, so only the declared records are malformed
+	rewrap := encodeFrame
+	data := frame[frameHeaderLen : len(frame)-frameCRCLen]
+	badCRC := append([]byte{}, frame...)
+	badCRC[len(badCRC)-1] ^= 0xff
+	tests := map[string]struct {
+		frame   []byte
+		errWant error
+	}{
+		"crc mismatch":       {frame: badCRC, errWant: ErrCheckCRC},
+		"short header":       {frame: rewrap(data[:1]), errWant: ErrInvalidHeader},
+		"truncated record":   {frame: rewrap(data[:20]), errWant: ErrTruncatedPacket},
+		"truncated io":       {frame: rewrap(data[:len(data)-4]), errWant: ErrTruncatedPacket},
+		"missing data count": {frame: rewrap(data[:len(data)-1]), errWant: ErrTruncatedPacket},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePacket(test.frame, "356307042441013")
+			assert.ErrorIs(t, err, test.errWant)
+		})
+	}
+}
+
+func TestIOElementID(t *testing.T) {
+	tests := map[string]struct {
+		name      string
+		wantID    uint16
+		wantFound bool
+	}{
+		"one byte name": {name: "Ignition", wantID: 239, wantFound: true},
+		"two byte name": {name: "ExternalVoltage", wantID: 66, wantFound: true},
+		"numeric name":  {name: "16", wantID: 16, wantFound: true},
+		"derived name":  {name: "VehicleSpeed"},
+		"out of range":  {name: "70000"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			id, found := IOElementID(test.name)
+			assert.Equal(t, found, test.wantFound)
+			assert.Equal(t, id, test.wantID)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidUDPPacket = errors.New("invalid udp packet")
+)
+
+const (
+	// udpHeaderLen is length (2), packet id (2), not usable byte (1), avl packet id (1) and imei length (2)
+	udpHeaderLen = 8
+	// udpNotUsableByte is sent by devices and echoed in acks
+	udpNotUsableByte = 0x01
+	// MaxUDPPacketLength is the biggest datagram accepted from a device
+	MaxUDPPacketLength = 64 * 1024
+)
+
+// UDPPacket is a decoded teltonika UDP channel packet
+type UDPPacket struct {
+	PacketID    uint16
+	AVLPacketID uint8
+	IMEI        string
+	// Frame is the AVL data wrapped as a tcp frame, so it is parsed and archived like tcp frames
+	Frame []byte
+}
+
+// DecodeUDPPacket decodes UDP channel header of data and validates IMEI with mode
+func DecodeUDPPacket(data []byte, mode IMEIMode) (*UDPPacket, error) {
+	if len(data) < udpHeaderLen {
+		return nil, fmt.Errorf("%w: header is truncated", ErrInvalidUDPPacket)
+	}
+	if int(binary.BigEndian.Uint16(data[:2])) != len(data)-2 {
+		return nil, fmt.Errorf("%w: length does not match datagram", ErrInvalidUDPPacket)
+	}
+	packet := &UDPPacket{
+		PacketID:    binary.BigEndian.Uint16(data[2:4]),
+		AVLPacketID: data[5],
+	}
+	imeiEnd := udpHeaderLen + int(binary.BigEndian.Uint16(data[6:8]))
+	if imeiEnd > len(data) {
+		return nil, fmt.Errorf("%w: imei is truncated", ErrInvalidUDPPacket)
+	}
+	packet.IMEI = string(data[udpHeaderLen:imeiEnd])
+	if err := ValidateIMEI(packet.IMEI, mode); err != nil {
+		return nil, err
+	}
+	avlData := data[imeiEnd:]
+	if len(avlData) < 3 {
+		return nil, fmt.Errorf("%w: avl data is truncated", ErrInvalidUDPPacket)
+	}
+	packet.Frame = encodeFrame(avlData)
+	return packet, nil
+}
+
+// EncodeUDPAck encodes ack of a UDP channel packet, accepted is number of accepted records
+func EncodeUDPAck(packetID uint16, avlPacketID uint8, accepted uint8) []byte {
+	ack := binary.BigEndian.AppendUint16(nil, 5)
+	ack = binary.BigEndian.AppendUint16(ack, packetID)
+	return append(ack, udpNotUsableByte, avlPacketID, accepted)
+}
+
+// MakeUDPPacket encodes points as a UDP channel packet with codec 8 extended
+func MakeUDPPacket(packetID uint16, avlPacketID uint8, imei string, points []*AVLData) ([]byte, error) {
+	frame, err := MakeCodec8Packet(points)
+	if err != nil {
+		return nil, err
+	}
+	avlData := frame[frameHeaderLen : len(frame)-frameCRCLen]
+	data := binary.BigEndian.AppendUint16(nil, uint16(udpHeaderLen-2+len(imei)+len(avlData)))
+	data = binary.BigEndian.AppendUint16(data, packetID)
+	data = append(data, udpNotUsableByte, avlPacketID)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(imei)))
+	data = append(data, imei...)
+	return append(data, avlData...), nil
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDecodeUDPPacket(t *testing.T) {
+	tests := map[string]struct {
+		errWant     error
+		dataString  string
+		mode        IMEIMode
+		packetID    uint16
+		avlPacketID uint8
+		imei        string
+		codecID     uint8
+		records     uint8
+	}{
+		"codec 8 example": {
+			dataString:  `003DCAFE0105000F33353230393330383634303336353508010000016B4F815B30010000000000000000000000000000000103021503010101425DBC000001`,
+			mode:        IMEIRelaxed,
+			packetID:    0xCAFE,
+			avlPacketID: 0x05,
+			imei:        "352093086403655",
+			codecID:     0x08,
+			records:     1,
+		},
+		"length mismatch": {
+			dataString: `003ECAFE0105000F33353230393330383634303336353508010000016B4F815B30010000000000000000000000000000000103021503010101425DBC000001`,
+			errWant:    ErrInvalidUDPPacket,
+		},
+		"truncated header": {
+			dataString: `0004CAFE0105`,
+			errWant:    ErrInvalidUDPPacket,
+		},
+		"truncated imei": {
+			dataString: `0008CAFE0105000F3335`,
+			errWant:    ErrInvalidUDPPacket,
+		},
+		"invalid imei": {
+			dataString: `0017CAFE0105000F3335323039333038363430333635410801`,
+			mode:       IMEIRelaxed,
+			errWant:    ErrIMEINotDigit,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dataBytes, err := hex.DecodeString(test.dataString)
+			assert.NilError(t, err)
+			packet, err := DecodeUDPPacket(dataBytes, test.mode)
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, packet.PacketID, test.packetID)
+			assert.Equal(t, packet.AVLPacketID, test.avlPacketID)
+			assert.Equal(t, packet.IMEI, test.imei)
+			assert.Equal(t, FrameCodecID(packet.Frame), test.codecID)
+			assert.Equal(t, FrameRecordCount(packet.Frame), test.records)
+			assert.Assert(t, VerifyCRC(packet.Frame))
+		})
+	}
+}
+
+func TestUDPPacketRoundTrip(t *testing.T) {
+	const imei = "356307042441013"
+	data, err := MakeUDPPacket(0x1234, 7, imei, []*AVLData{
+		{Timestamps: 1700000000000, Priority: PriorityHigh, Longitude: 51.4, Latitude: 35.7, Speed: 40},
+		{Timestamps: 1700000001000, Priority: PriorityLow, Longitude: 51.5, Latitude: 35.8, Speed: 45},
+	})
+	assert.NilError(t, err)
+	packet, err := DecodeUDPPacket(data, IMEIStrict)
+	assert.NilError(t, err)
+	assert.Equal(t, packet.IMEI, imei)
+	points, err := ParsePacket(packet.Frame, packet.IMEI)
+	assert.NilError(t, err)
+	assert.Equal(t, len(points), 2)
+	assert.Equal(t, points[1].GetGps().GetSpeed(), int32(45))
+
+	ack := EncodeUDPAck(packet.PacketID, packet.AVLPacketID, uint8(len(points)))
+	assert.Equal(t, hex.EncodeToString(ack), "00051234010702")
+}
@@ -8,15 +8,90 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"sync"
 	"time"
 
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"go.uber.org/zap"
 	"golang.org/x/exp/constraints"
 )
 
 var (
-	ErrInvalidIMEI = errors.New("IMEI must be 15 characters long")
+	ErrInvalidIMEI  = errors.New("IMEI must be 15 characters long")
+	ErrIMEINotDigit = errors.New("IMEI must contain only digits")
+	ErrIMEIChecksum = errors.New("IMEI luhn check digit mismatch")
 )
 
+// IMEILength is the number of digits of an IMEI including its check digit
+const IMEILength = 15
+
+// IMEIMode selects how strictly IMEIs are validated
+type IMEIMode uint8
+
+const (
+	// IMEIStrict requires 15 digits with a valid luhn check digit
+	IMEIStrict IMEIMode = iota
+	// IMEIRelaxed requires 15 digits without checking luhn check digit, used for test devices
+	IMEIRelaxed
+)
+
+// TimestampLayout is the layout of AVLData timestamps, they are in TimestampLocation
+const TimestampLayout = "2006-01-02 15:04:05"
+
+var (
+	timestampLocation     *time.Location
+	timestampLocationOnce sync.Once
+)
+
+// TimestampLocation returns location of AVLData timestamps
+func TimestampLocation() *time.Location {
+	timestampLocationOnce.Do(func() {
+		location, err := time.LoadLocation("Asia/Tehran")
+		if err != nil {
+			logger.Load().Warn("load timestamp location failed, using UTC", zap.Error(err))
+			location = time.UTC
+		}
+		timestampLocation = location
+	})
+	return timestampLocation
+}
+
+// FormatTimestamp formats time as AVLData timestamp
+func FormatTimestamp(timeValue time.Time) string {
+	return timeValue.In(TimestampLocation()).Format(TimestampLayout)
+}
+
+// ParseTimestamp parses AVLData timestamp
+func ParseTimestamp(value string) (time.Time, error) {
+	return time.ParseInLocation(TimestampLayout, value, TimestampLocation())
+}
+
+// NewestPoint returns the point with the latest timestamp, devices upload stored records after
+// a coverage gap so the last point of a packet is not always the newest one. Later points win
+// ties, points with invalid timestamps are skipped unless no point has a valid one
+func NewestPoint(points []*pb.AVLData) *pb.AVLData {
+	if len(points) == 0 {
+		return nil
+	}
+	var (
+		newest     *pb.AVLData
+		newestTime time.Time
+	)
+	for _, point := range points {
+		timestamp, err := ParseTimestamp(point.GetTimestamp())
+		if err != nil {
+			continue
+		}
+		if newest == nil || !timestamp.Before(newestTime) {
+			newest, newestTime = point, timestamp
+		}
+	}
+	if newest == nil {
+		return points[len(points)-1]
+	}
+	return newest
+}
+
 func streamToInt32(data []byte) (int32, error) {
 	var y int32
 	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &y)
@@ -37,7 +112,6 @@ func streamToNumber[T constraints.Integer | constraints.Float](data []byte) (T,
 func numberToStream(value any) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := binary.Write(buf, binary.BigEndian, value); err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -57,7 +131,13 @@ func streamToTime(data []byte) (int64, error) {
 	return time.Unix(seconds, nanoseconds).Unix(), err
 }
 
+// DecodeIMEI decodes IMEI handshake packet and validates IMEI strictly
 func DecodeIMEI(data []byte) (string, error) {
+	return DecodeIMEIWithMode(data, IMEIStrict)
+}
+
+// DecodeIMEIWithMode decodes IMEI handshake packet and validates IMEI with mode
+func DecodeIMEIWithMode(data []byte, mode IMEIMode) (string, error) {
 	if len(data) < 2 {
 		return "", errors.New("invalid imei bytes length")
 	}
@@ -75,12 +155,49 @@ func DecodeIMEI(data []byte) (string, error) {
 	if len(imei) != int(imeiLength) {
 		return "", fmt.Errorf("invalid imei length")
 	}
+	if e := ValidateIMEI(imei, mode); e != nil {
+		return "", e
+	}
 	return imei, nil
 }
 
+// ValidateIMEI checks IMEI has 15 digits and, in strict mode, a valid luhn check digit
+func ValidateIMEI(imei string, mode IMEIMode) error {
+	if len(imei) != IMEILength {
+		return ErrInvalidIMEI
+	}
+	for i := 0; i < len(imei); i++ {
+		if imei[i] < '0' || imei[i] > '9' {
+			return ErrIMEINotDigit
+		}
+	}
+	if mode == IMEIStrict && LuhnCheckDigit(imei[:IMEILength-1]) != imei[IMEILength-1] {
+		return ErrIMEIChecksum
+	}
+	return nil
+}
+
+// LuhnCheckDigit returns luhn check digit of digits, digits must contain only '0'-'9'
+func LuhnCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		// every second digit starting from the rightmost is doubled
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// EncodeIMEIToHex encodes IMEI handshake packet, IMEI is validated strictly
 func EncodeIMEIToHex(imei string) ([]byte, error) {
-	if len(imei) != 15 {
-		return nil, ErrInvalidIMEI
+	if err := ValidateIMEI(imei, IMEIStrict); err != nil {
+		return nil, err
 	}
 	imeiHex := "000F" + hex.EncodeToString([]byte(imei))
 	imeiBytes, err := hex.DecodeString(imeiHex)
@@ -90,8 +207,9 @@ func EncodeIMEIToHex(imei string) ([]byte, error) {
 	return imeiBytes, nil
 }
 
+// calculateCRC16 calculates CRC-16/IBM used by teltonika data packets
 func calculateCRC16(data []byte) uint16 {
-	crc := uint16(0xFFFF) // Initial CRC value
+	crc := uint16(0) // Initial CRC value
 
 	for _, b := range data {
 		crc ^= uint16(b)
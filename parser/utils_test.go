@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"testing"
 
+	pb "github.com/irisco88/protos/gen/device/v1"
 	"gotest.tools/v3/assert"
 )
 
@@ -18,6 +19,18 @@ func TestDecodeIMEI(t *testing.T) {
 			imeiHex:    "000F333536333037303432343431303133",
 			imeiResult: "356307042441013",
 		},
+		"luhn mismatch": {
+			errWant: ErrIMEIChecksum,
+			imeiHex: "000F333536333037303432343431303134",
+		},
+		"not digits": {
+			errWant: ErrIMEINotDigit,
+			imeiHex: "000F33353633303730343234343130314A",
+		},
+		"short imei": {
+			errWant: ErrInvalidIMEI,
+			imeiHex: "000E3335363330373034323434313031",
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -45,6 +58,10 @@ func TestEncodeIMEI(t *testing.T) {
 			imeiHex: "000F333536333037303432343431303133",
 			imei:    "356307042441013",
 		},
+		"luhn mismatch": {
+			errWant: ErrIMEIChecksum,
+			imei:    "356307042441014",
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -60,3 +77,45 @@ func TestEncodeIMEI(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeIMEIRelaxed(t *testing.T) {
+	imeiBytes, err := hex.DecodeString("000F333536333037303432343431303134")
+	assert.NilError(t, err)
+	imei, err := DecodeIMEIWithMode(imeiBytes, IMEIRelaxed)
+	assert.NilError(t, err)
+	assert.Equal(t, imei, "356307042441014")
+
+	imeiBytes, err = hex.DecodeString("000F33353633303730343234343130314A")
+	assert.NilError(t, err)
+	_, err = DecodeIMEIWithMode(imeiBytes, IMEIRelaxed)
+	assert.ErrorIs(t, err, ErrIMEINotDigit)
+}
+
+func TestLuhnCheckDigit(t *testing.T) {
+	for _, imei := range []string{"356307042441013", "352094087982671", "490154203237518"} {
+		assert.Equal(t, LuhnCheckDigit(imei[:14]), imei[14], imei)
+	}
+}
+
+func TestNewestPoint(t *testing.T) {
+	tests := map[string]struct {
+		timestamps []string
+		want       int
+	}{
+		"last":              {timestamps: []string{"2023-09-27 07:33:20", "2023-09-27 07:33:30"}, want: 1},
+		"backlog after gap": {timestamps: []string{"2023-09-27 09:00:00", "2023-09-27 07:33:20", "2023-09-27 07:33:30"}, want: 0},
+		"later wins ties":   {timestamps: []string{"2023-09-27 07:33:20", "2023-09-27 07:33:20"}, want: 1},
+		"invalid skipped":   {timestamps: []string{"2023-09-27 07:33:20", "invalid"}, want: 0},
+		"all invalid":       {timestamps: []string{"invalid", ""}, want: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			points := make([]*pb.AVLData, len(test.timestamps))
+			for i, timestamp := range test.timestamps {
+				points[i] = &pb.AVLData{Timestamp: timestamp}
+			}
+			assert.Assert(t, NewestPoint(points) == points[test.want])
+		})
+	}
+	assert.Assert(t, NewestPoint(nil) == nil)
+}
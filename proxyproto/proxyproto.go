@@ -0,0 +1,231 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoHeader      = errors.New("proxy protocol header is missing")
+	ErrInvalidHeader = errors.New("invalid proxy protocol header")
+)
+
+const (
+	// v1MaxLength is the longest v1 header including CRLF
+	v1MaxLength = 107
+	v2HeaderLen = 16
+
+	v2CommandLocal = 0x0
+	v2CommandProxy = 0x1
+
+	v2FamilyTCP4 = 0x11
+	v2FamilyTCP6 = 0x21
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// Header is a decoded PROXY protocol header, addresses are nil for LOCAL and UNKNOWN connections
+type Header struct {
+	Version    int
+	SourceAddr *net.TCPAddr
+	DestAddr   *net.TCPAddr
+}
+
+// ReadHeader reads a v1 or v2 header from the start of reader
+func ReadHeader(reader *bufio.Reader) (*Header, error) {
+	peek, err := reader.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(peek, v1Prefix) {
+		return readV1(reader)
+	}
+	peek, err = reader.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(peek, v2Signature) {
+		return readV2(reader)
+	}
+	return nil, ErrNoHeader
+}
+
+// readV1 reads "PROXY TCP4 src dst sport dport\r\n"
+func readV1(reader *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 header is not terminated", ErrInvalidHeader)
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	header := &Header{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	var err error
+	if header.SourceAddr, err = parseV1Addr(fields[2], fields[4]); err != nil {
+		return nil, err
+	}
+	if header.DestAddr, err = parseV1Addr(fields[3], fields[5]); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func parseV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidHeader, host)
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrInvalidHeader, port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(portNumber)}, nil
+}
+
+// readV2 reads binary header, TLVs are skipped
+func readV2(reader *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, v2HeaderLen)
+	if _, err := io.ReadFull(reader, fixed); err != nil {
+		return nil, err
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, fixed[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	header := &Header{Version: 2}
+	switch fixed[12] & 0x0f {
+	case v2CommandLocal:
+		return header, nil
+	case v2CommandProxy:
+	default:
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidHeader, fixed[12]&0x0f)
+	}
+	var ipLen int
+	switch fixed[13] {
+	case v2FamilyTCP4:
+		ipLen = net.IPv4len
+	case v2FamilyTCP6:
+		ipLen = net.IPv6len
+	default:
+		// other families carry no address usable for tcp connections
+		return header, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	}
+	header.SourceAddr = &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	header.DestAddr = &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return header, nil
+}
+
+// Conn reports addresses of PROXY protocol header, bytes buffered while reading header are read first
+type Conn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+// Accept reads PROXY protocol header of conn and returns a connection reporting real client address
+func Accept(conn net.Conn) (*Conn, *Header, error) {
+	reader := bufio.NewReaderSize(conn, v1MaxLength)
+	header, err := ReadHeader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyConn := &Conn{
+		Conn:       conn,
+		reader:     reader,
+		remoteAddr: conn.RemoteAddr(),
+		localAddr:  conn.LocalAddr(),
+	}
+	if header.SourceAddr != nil {
+		proxyConn.remoteAddr = header.SourceAddr
+		proxyConn.localAddr = header.DestAddr
+	}
+	return proxyConn, header, nil
+}
+
+// ParseCIDRs parses trusted source networks, single ips are accepted as host networks
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q:%v", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Trusted reports whether addr is in one of networks
+func Trusted(addr net.Addr, networks []*net.IPNet) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func v2Header(command, family byte, addresses []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func v2Addresses(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	addresses := append(append([]byte{}, src...), dst...)
+	addresses = binary.BigEndian.AppendUint16(addresses, srcPort)
+	return binary.BigEndian.AppendUint16(addresses, dstPort)
+}
+
+func TestReadHeader(t *testing.T) {
+	tests := map[string]struct {
+		data     []byte
+		wantSrc  string
+		wantDst  string
+		wantVer  int
+		errWant  error
+		wantRest string
+	}{
+		"v1 tcp4": {
+			data:     []byte("PROXY TCP4 10.1.2.3 192.168.0.1 45678 5000\r\nrest"),
+			wantSrc:  "10.1.2.3:45678",
+			wantDst:  "192.168.0.1:5000",
+			wantVer:  1,
+			wantRest: "rest",
+		},
+		"v1 tcp6": {
+			data:    []byte("PROXY TCP6 2001:db8::1 2001:db8::2 45678 5000\r\n"),
+			wantSrc: "[2001:db8::1]:45678",
+			wantDst: "[2001:db8::2]:5000",
+			wantVer: 1,
+		},
+		"v1 unknown": {
+			data:    []byte("PROXY UNKNOWN\r\n"),
+			wantVer: 1,
+		},
+		"v1 invalid address": {
+			data:    []byte("PROXY TCP4 10.1.2 192.168.0.1 45678 5000\r\n"),
+			errWant: ErrInvalidHeader,
+		},
+		"v1 not terminated": {
+			data:    append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), v1MaxLength)...),
+			errWant: ErrInvalidHeader,
+		},
+		"v2 tcp4": {
+			data: append(v2Header(v2CommandProxy, v2FamilyTCP4,
+				v2Addresses(net.IPv4(10, 1, 2, 3).To4(), net.IPv4(192, 168, 0, 1).To4(), 45678, 5000)), "rest"...),
+			wantSrc:  "10.1.2.3:45678",
+			wantDst:  "192.168.0.1:5000",
+			wantVer:  2,
+			wantRest: "rest",
+		},
+		"v2 tcp6 with tlv": {
+			data: v2Header(v2CommandProxy, v2FamilyTCP6,
+				append(v2Addresses(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 45678, 5000), 0x04, 0, 1, 0)),
+			wantSrc: "[2001:db8::1]:45678",
+			wantDst: "[2001:db8::2]:5000",
+			wantVer: 2,
+		},
+		"v2 local": {
+			data:    v2Header(v2CommandLocal, 0, nil),
+			wantVer: 2,
+		},
+		"v2 short addresses": {
+			data:    v2Header(v2CommandProxy, v2FamilyTCP4, []byte{10, 1, 2, 3}),
+			errWant: ErrInvalidHeader,
+		},
+		"missing header": {
+			data:    []byte{0, 15, '3', '5', '6', '3', '0', '7', '0', '4', '2', '4', '4', '1', '0', '1', '3'},
+			errWant: ErrNoHeader,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := bufio.NewReader(bytes.NewReader(test.data))
+			header, err := ReadHeader(reader)
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, header.Version, test.wantVer)
+			if test.wantSrc == "" {
+				assert.Assert(t, header.SourceAddr == nil)
+			} else {
+				assert.Equal(t, header.SourceAddr.String(), test.wantSrc)
+				assert.Equal(t, header.DestAddr.String(), test.wantDst)
+			}
+			rest, err := io.ReadAll(reader)
+			assert.NilError(t, err)
+			assert.Equal(t, string(rest), test.wantRest)
+		})
+	}
+}
+
+func TestTrusted(t *testing.T) {
+	networks, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"})
+	assert.NilError(t, err)
+	tests := map[string]struct {
+		addr net.Addr
+		want bool
+	}{
+		"cidr":      {addr: &net.TCPAddr{IP: net.ParseIP("10.20.30.40")}, want: true},
+		"single ip": {addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.10")}, want: true},
+		"ipv6":      {addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::5")}, want: true},
+		"untrusted": {addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.11")}},
+		"not tcp":   {addr: &net.UnixAddr{Name: "/tmp/sock"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, Trusted(test.addr, networks), test.want)
+		})
+	}
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.Assert(t, err != nil)
+}
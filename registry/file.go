@@ -0,0 +1,40 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+var _ Reloader = &FileStore{}
+
+// FileStore loads devices from a json array file, changes are applied by Reload
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	fs := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+	}
+	if err := fs.Reload(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Reload reads devices file again and replaces loaded devices, loaded devices are kept when the file is invalid
+func (fs *FileStore) Reload() error {
+	data, err := os.ReadFile(fs.path)
+	if err != nil {
+		return err
+	}
+	var devices []*Device
+	if e := json.Unmarshal(data, &devices); e != nil {
+		return fmt.Errorf("decode devices file failed:%v", e)
+	}
+	fs.Set(devices)
+	return nil
+}
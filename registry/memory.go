@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"context"
+	"sync"
+)
+
+var _ Store = &MemoryStore{}
+
+// MemoryStore keeps devices in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	devices map[string]*Device
+}
+
+func NewMemoryStore(devices ...*Device) *MemoryStore {
+	ms := &MemoryStore{}
+	ms.Set(devices)
+	return ms
+}
+
+// Set replaces all devices of store
+func (ms *MemoryStore) Set(devices []*Device) {
+	deviceMap := make(map[string]*Device, len(devices))
+	for _, device := range devices {
+		deviceMap[device.IMEI] = device
+	}
+	ms.mu.Lock()
+	ms.devices = deviceMap
+	ms.mu.Unlock()
+}
+
+func (ms *MemoryStore) Device(_ context.Context, imei string) (*Device, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	device, found := ms.devices[imei]
+	if !found {
+		return nil, ErrUnknownDevice
+	}
+	return device, nil
+}
+
+func (ms *MemoryStore) Close() error {
+	return nil
+}
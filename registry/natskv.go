@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+var _ Store = &NATSKVStore{}
+
+// NATSKVStore looks up devices in a nats key value bucket, keys are imei and values are json devices
+type NATSKVStore struct {
+	kv nats.KeyValue
+}
+
+func NewNATSKVStore(natsConn *nats.Conn, bucket string) (*NATSKVStore, error) {
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	kv, err := js.KeyValue(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSKVStore{
+		kv: kv,
+	}, nil
+}
+
+func (ns *NATSKVStore) Device(_ context.Context, imei string) (*Device, error) {
+	entry, err := ns.kv.Get(imei)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, ErrUnknownDevice
+	}
+	if err != nil {
+		return nil, err
+	}
+	device := &Device{}
+	if e := json.Unmarshal(entry.Value(), device); e != nil {
+		return nil, fmt.Errorf("decode device %s failed:%v", imei, e)
+	}
+	device.IMEI = imei
+	return device, nil
+}
+
+func (ns *NATSKVStore) Close() error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/nats-io/nats.go"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported registry url scheme, use file://, postgres:// or natskv://")
+
+// Open opens device store selected by registryURL scheme.
+// A path without scheme is a devices file, natskv://bucket uses bucket of natsConn.
+func Open(registryURL string, natsConn *nats.Conn) (Store, error) {
+	parsed, err := url.Parse(registryURL)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "":
+		return NewFileStore(registryURL)
+	case "file":
+		return NewFileStore(parsed.Path)
+	case "postgres", "postgresql":
+		return NewPostgresStore(registryURL)
+	case "natskv":
+		return NewNATSKVStore(natsConn, parsed.Host)
+	}
+	return nil, ErrUnsupportedScheme
+}
@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ Store = &PostgresStore{}
+
+const selectDeviceQuery = `
+	SELECT imei, name, status FROM devices WHERE imei = $1;
+`
+
+// PostgresStore looks up devices table created by postgres avldb migrations
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(context.Background(), databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if e := pool.Ping(context.Background()); e != nil {
+		pool.Close()
+		return nil, e
+	}
+	return &PostgresStore{
+		pool: pool,
+	}, nil
+}
+
+func (ps *PostgresStore) Device(ctx context.Context, imei string) (*Device, error) {
+	device := &Device{}
+	err := ps.pool.QueryRow(ctx, selectDeviceQuery, imei).Scan(&device.IMEI, &device.Name, &device.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUnknownDevice
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (ps *PostgresStore) Close() error {
+	ps.pool.Close()
+	return nil
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownDevice  = errors.New("unknown device")
+	ErrDeviceDisabled = errors.New("device is disabled")
+	ErrDeviceBlocked  = errors.New("device is blocked")
+	ErrInvalidStatus  = errors.New("device status is not active")
+)
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusDisabled Status = "disabled"
+	StatusBlocked  Status = "blocked"
+)
+
+// Device is a registered tracker, empty status is treated as active
+type Device struct {
+	IMEI   string `json:"imei"`
+	Name   string `json:"name,omitempty"`
+	Status Status `json:"status,omitempty"`
+}
+
+// Store looks up registered devices, ErrUnknownDevice is returned for unregistered imei
+type Store interface {
+	Device(ctx context.Context, imei string) (*Device, error)
+	Close() error
+}
+
+// Reloader is a store which reads its devices again on demand, the server reloads it on SIGHUP
+type Reloader interface {
+	Reload() error
+}
+
+// Authenticator decides whether a device may send data after its imei is decoded
+type Authenticator interface {
+	Authenticate(ctx context.Context, imei string) error
+}
+
+var _ Authenticator = &StoreAuthenticator{}
+
+// StoreAuthenticator accepts only active devices of store, devices with any other status are declined
+type StoreAuthenticator struct {
+	store Store
+}
+
+func NewAuthenticator(store Store) *StoreAuthenticator {
+	return &StoreAuthenticator{
+		store: store,
+	}
+}
+
+func (sa *StoreAuthenticator) Authenticate(ctx context.Context, imei string) error {
+	device, err := sa.store.Device(ctx, imei)
+	if err != nil {
+		return err
+	}
+	switch device.Status {
+	case StatusActive, "":
+		return nil
+	case StatusDisabled:
+		return ErrDeviceDisabled
+	case StatusBlocked:
+		return ErrDeviceBlocked
+	}
+	return fmt.Errorf("%w:%q", ErrInvalidStatus, device.Status)
+}
+
+// Reason returns a short label of authentication error, used in logs and metrics
+func Reason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownDevice):
+		return "unknown"
+	case errors.Is(err, ErrDeviceDisabled):
+		return "disabled"
+	case errors.Is(err, ErrDeviceBlocked):
+		return "blocked"
+	case errors.Is(err, ErrInvalidStatus):
+		return "invalid_status"
+	}
+	return "error"
+}
+
+// Rejected reports whether err declines the device, other errors mean the registry could not be read
+func Rejected(err error) bool {
+	return errors.Is(err, ErrUnknownDevice) || errors.Is(err, ErrDeviceDisabled) || errors.Is(err, ErrDeviceBlocked) ||
+		errors.Is(err, ErrInvalidStatus)
+}
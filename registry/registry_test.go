@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"gotest.tools/v3/assert"
+)
+
+func TestStoreAuthenticator(t *testing.T) {
+	auth := NewAuthenticator(NewMemoryStore(
+		&Device{IMEI: "356307042441013", Status: StatusActive},
+		&Device{IMEI: "356307042441014"},
+		&Device{IMEI: "356307042441015", Status: StatusDisabled},
+		&Device{IMEI: "356307042441016", Status: StatusBlocked},
+		&Device{IMEI: "356307042441018", Status: "Active"},
+		&Device{IMEI: "356307042441019", Status: "suspended"},
+	))
+	tests := map[string]struct {
+		imei       string
+		errWant    error
+		reasonWant string
+	}{
+		"active":         {imei: "356307042441013"},
+		"empty status":   {imei: "356307042441014"},
+		"disabled":       {imei: "356307042441015", errWant: ErrDeviceDisabled, reasonWant: "disabled"},
+		"blocked":        {imei: "356307042441016", errWant: ErrDeviceBlocked, reasonWant: "blocked"},
+		"unknown device": {imei: "356307042441017", errWant: ErrUnknownDevice, reasonWant: "unknown"},
+		"other case":     {imei: "356307042441018", errWant: ErrInvalidStatus, reasonWant: "invalid_status"},
+		"unknown status": {imei: "356307042441019", errWant: ErrInvalidStatus, reasonWant: "invalid_status"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := auth.Authenticate(context.Background(), test.imei)
+			if test.errWant == nil {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, test.errWant)
+			assert.Equal(t, Reason(err), test.reasonWant)
+			assert.Assert(t, Rejected(err))
+		})
+	}
+}
+
+func TestRejected(t *testing.T) {
+	assert.Assert(t, Rejected(fmt.Errorf("lookup: %w", ErrUnknownDevice)))
+	assert.Assert(t, !Rejected(errors.New("connection refused")))
+	assert.Equal(t, Reason(errors.New("connection refused")), "error")
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	assert.NilError(t, os.WriteFile(path, []byte(`[{"imei":"356307042441013","name":"truck 1"}]`), 0o644))
+	store, err := Open("file://"+path, nil)
+	assert.NilError(t, err)
+	device, err := store.Device(context.Background(), "356307042441013")
+	assert.NilError(t, err)
+	assert.Equal(t, device.Name, "truck 1")
+
+	assert.NilError(t, os.WriteFile(path, []byte(`[{"imei":"356307042441014","status":"blocked"}]`), 0o644))
+	assert.NilError(t, store.(*FileStore).Reload())
+	_, err = store.Device(context.Background(), "356307042441013")
+	assert.ErrorIs(t, err, ErrUnknownDevice)
+	device, err = store.Device(context.Background(), "356307042441014")
+	assert.NilError(t, err)
+	assert.Equal(t, device.Status, StatusBlocked)
+
+	// devices are kept when the edited file is invalid
+	assert.NilError(t, os.WriteFile(path, []byte(`[{"imei":`), 0o644))
+	assert.Assert(t, store.(Reloader).Reload() != nil)
+	_, err = store.Device(context.Background(), "356307042441014")
+	assert.NilError(t, err)
+}
+
+func TestNATSKVStore(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	natsServer := natstest.RunServer(&opts)
+	defer natsServer.Shutdown()
+	natsConn, err := nats.Connect(natsServer.ClientURL())
+	assert.NilError(t, err)
+	defer natsConn.Close()
+	js, err := natsConn.JetStream()
+	assert.NilError(t, err)
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "devices"})
+	assert.NilError(t, err)
+	_, err = kv.Put("356307042441013", []byte(`{"name":"truck 1","status":"disabled"}`))
+	assert.NilError(t, err)
+
+	store, err := Open("natskv://devices", natsConn)
+	assert.NilError(t, err)
+	device, err := store.Device(context.Background(), "356307042441013")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, device, &Device{IMEI: "356307042441013", Name: "truck 1", Status: StatusDisabled})
+	_, err = store.Device(context.Background(), "356307042441014")
+	assert.ErrorIs(t, err, ErrUnknownDevice)
+}
+
+func TestOpenUnsupportedScheme(t *testing.T) {
+	_, err := Open("mysql://localhost/devices", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+}
@@ -0,0 +1,259 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNoIMEI           = errors.New("at least one imei is required")
+	ErrInvalidTimeRange = errors.New("time range end must be after start")
+)
+
+// DefaultBatchSize is the number of points written to target table in one insert
+const DefaultBatchSize = 10000
+
+// Store is the storage needed to re-decode archived raw frames
+type Store interface {
+	CountRawData(ctx context.Context, filter *db.RawFrameFilter) (uint64, error)
+	LoadRawData(ctx context.Context, filter *db.RawFrameFilter, fn func(frame *db.RawFrame) error) error
+	LoadAvlPointsAt(ctx context.Context, table, imei string, timestamps []string) ([]*pb.AVLData, error)
+	SaveAvlPointsToTable(ctx context.Context, table string, points []*pb.AVLData) error
+	CreateTableLike(ctx context.Context, table, source string) error
+}
+
+// Options configures a reprocess run
+type Options struct {
+	Filter *db.RawFrameFilter
+	// TargetTable receives corrected points, avlpoints or a shadow table
+	TargetTable string
+	// DryRun only prints the difference between stored and re-decoded points
+	DryRun bool
+	// ProgressEvery logs progress after this many frames
+	ProgressEvery uint64
+	// BatchSize is the number of re-decoded points collected across frames before each write
+	BatchSize int
+}
+
+// Result summarizes a reprocess run
+type Result struct {
+	Frames        uint64
+	FailedFrames  uint64
+	Points        uint64
+	ChangedPoints uint64
+	NewPoints     uint64
+}
+
+type Reprocessor struct {
+	store Store
+	log   *zap.Logger
+	out   io.Writer
+}
+
+func NewReprocessor(store Store, logger *zap.Logger, out io.Writer) *Reprocessor {
+	return &Reprocessor{
+		store: store,
+		log:   logger,
+		out:   out,
+	}
+}
+
+// Run re-decodes archived raw frames with current parser and writes them to target table
+func (r *Reprocessor) Run(ctx context.Context, opts *Options) (*Result, error) {
+	if len(opts.Filter.IMEIs) == 0 {
+		return nil, ErrNoIMEI
+	}
+	if !opts.Filter.To.After(opts.Filter.From) {
+		return nil, ErrInvalidTimeRange
+	}
+	if opts.TargetTable == "" {
+		opts.TargetTable = db.AvlPointsTable
+	}
+	if opts.ProgressEvery == 0 {
+		opts.ProgressEvery = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	total, err := r.store.CountRawData(ctx, opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("count raw data failed:%v", err)
+	}
+	r.log.Info("reprocess started",
+		zap.Strings("imeis", opts.Filter.IMEIs),
+		zap.Time("from", opts.Filter.From),
+		zap.Time("to", opts.Filter.To),
+		zap.String("table", opts.TargetTable),
+		zap.Bool("dryRun", opts.DryRun),
+		zap.String("parserVersion", parser.Version),
+		zap.Uint64("frames", total),
+	)
+	if !opts.DryRun && opts.TargetTable != db.AvlPointsTable {
+		if e := r.store.CreateTableLike(ctx, opts.TargetTable, db.AvlPointsTable); e != nil {
+			return nil, fmt.Errorf("create target table failed:%v", e)
+		}
+	}
+
+	result := &Result{}
+	startedAt := time.Now()
+	batch := make([]*pb.AVLData, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if e := r.store.SaveAvlPointsToTable(ctx, opts.TargetTable, batch); e != nil {
+			return fmt.Errorf("save reprocessed points failed:%w", e)
+		}
+		batch = make([]*pb.AVLData, 0, opts.BatchSize)
+		return nil
+	}
+	err = r.store.LoadRawData(ctx, opts.Filter, func(frame *db.RawFrame) error {
+		result.Frames++
+		defer func() {
+			if result.Frames%opts.ProgressEvery == 0 || result.Frames == total {
+				r.logProgress(result, total, startedAt)
+			}
+		}()
+		points, parseErr := parser.ParsePacket(frame.Payload, frame.IMEI)
+		if parseErr != nil {
+			result.FailedFrames++
+			r.log.Warn("parse raw frame failed",
+				zap.String("imei", frame.IMEI),
+				zap.Time("receivedAt", frame.ReceivedAt),
+				zap.Error(parseErr),
+			)
+			return nil
+		}
+		result.Points += uint64(len(points))
+		if opts.DryRun {
+			return r.diff(ctx, opts.TargetTable, frame, points, result)
+		}
+		batch = append(batch, points...)
+		if len(batch) >= opts.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if err = flush(); err != nil {
+		return result, err
+	}
+	r.log.Info("reprocess finished",
+		zap.Uint64("frames", result.Frames),
+		zap.Uint64("failedFrames", result.FailedFrames),
+		zap.Uint64("points", result.Points),
+		zap.Uint64("changedPoints", result.ChangedPoints),
+		zap.Uint64("newPoints", result.NewPoints),
+		zap.Duration("elapsed", time.Since(startedAt)),
+	)
+	return result, nil
+}
+
+func (r *Reprocessor) logProgress(result *Result, total uint64, startedAt time.Time) {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(result.Frames) * 100 / float64(total)
+	}
+	r.log.Info("reprocess progress",
+		zap.Uint64("frames", result.Frames),
+		zap.Uint64("total", total),
+		zap.String("percent", fmt.Sprintf("%.1f", percent)),
+		zap.Uint64("failedFrames", result.FailedFrames),
+		zap.Duration("elapsed", time.Since(startedAt)),
+	)
+}
+
+// diff prints difference between stored points and re-decoded points of a frame
+func (r *Reprocessor) diff(ctx context.Context, table string, frame *db.RawFrame, points []*pb.AVLData, result *Result) error {
+	timestamps := make([]string, 0, len(points))
+	for _, point := range points {
+		timestamps = append(timestamps, point.GetTimestamp())
+	}
+	stored, err := r.store.LoadAvlPointsAt(ctx, table, frame.IMEI, timestamps)
+	if err != nil {
+		return fmt.Errorf("load stored points failed:%v", err)
+	}
+	storedByTime := make(map[string]*pb.AVLData, len(stored))
+	for _, point := range stored {
+		storedByTime[point.GetTimestamp()] = point
+	}
+	for _, point := range points {
+		old, found := storedByTime[point.GetTimestamp()]
+		if !found {
+			result.NewPoints++
+			fmt.Fprintf(r.out, "+ %s %s\n", point.GetImei(), point.GetTimestamp())
+			continue
+		}
+		changes := DiffPoints(old, point)
+		if len(changes) == 0 {
+			continue
+		}
+		result.ChangedPoints++
+		fmt.Fprintf(r.out, "~ %s %s\n", point.GetImei(), point.GetTimestamp())
+		for _, change := range changes {
+			fmt.Fprintf(r.out, "    %s\n", change)
+		}
+	}
+	return nil
+}
+
+// DiffPoints returns human readable changes from stored to decoded point
+func DiffPoints(stored, decoded *pb.AVLData) []string {
+	var changes []string
+	addChange := func(field string, oldValue, newValue any) {
+		if fmt.Sprint(oldValue) != fmt.Sprint(newValue) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, oldValue, newValue))
+		}
+	}
+	addChange("priority", stored.GetPriority(), decoded.GetPriority())
+	addChange("event_id", stored.GetEventId(), decoded.GetEventId())
+	addChange("longitude", stored.GetGps().GetLongitude(), decoded.GetGps().GetLongitude())
+	addChange("latitude", stored.GetGps().GetLatitude(), decoded.GetGps().GetLatitude())
+	addChange("altitude", stored.GetGps().GetAltitude(), decoded.GetGps().GetAltitude())
+	addChange("angle", stored.GetGps().GetAngle(), decoded.GetGps().GetAngle())
+	addChange("satellites", stored.GetGps().GetSatellites(), decoded.GetGps().GetSatellites())
+	addChange("speed", stored.GetGps().GetSpeed(), decoded.GetGps().GetSpeed())
+
+	oldElements := make(map[string]float64, len(stored.GetIoElements()))
+	for _, element := range stored.GetIoElements() {
+		oldElements[element.GetElementName()] = element.GetElementValue()
+	}
+	newElements := make(map[string]float64, len(decoded.GetIoElements()))
+	for _, element := range decoded.GetIoElements() {
+		newElements[element.GetElementName()] = element.GetElementValue()
+	}
+	names := make([]string, 0, len(oldElements)+len(newElements))
+	for name := range oldElements {
+		names = append(names, name)
+	}
+	for name := range newElements {
+		if _, found := oldElements[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		oldValue, oldFound := oldElements[name]
+		newValue, newFound := newElements[name]
+		switch {
+		case !oldFound:
+			changes = append(changes, fmt.Sprintf("io %s: + %v", name, newValue))
+		case !newFound:
+			changes = append(changes, fmt.Sprintf("io %s: - %v", name, oldValue))
+		case oldValue != newValue:
+			changes = append(changes, fmt.Sprintf("io %s: %v -> %v", name, oldValue, newValue))
+		}
+	}
+	return changes
+}
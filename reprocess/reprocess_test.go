@@ -0,0 +1,174 @@
+package reprocess
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+type fakeStore struct {
+	frames  []*db.RawFrame
+	stored  []*pb.AVLData
+	saved   map[string][]*pb.AVLData
+	created []string
+	saves   int
+}
+
+func (fs *fakeStore) CountRawData(_ context.Context, _ *db.RawFrameFilter) (uint64, error) {
+	return uint64(len(fs.frames)), nil
+}
+
+func (fs *fakeStore) LoadRawData(_ context.Context, _ *db.RawFrameFilter, fn func(frame *db.RawFrame) error) error {
+	for _, frame := range fs.frames {
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fakeStore) LoadAvlPointsAt(_ context.Context, _, _ string, _ []string) ([]*pb.AVLData, error) {
+	return fs.stored, nil
+}
+
+func (fs *fakeStore) SaveAvlPointsToTable(_ context.Context, table string, points []*pb.AVLData) error {
+	fs.saves++
+	fs.saved[table] = append(fs.saved[table], points...)
+	return nil
+}
+
+func (fs *fakeStore) CreateTableLike(_ context.Context, table, _ string) error {
+	fs.created = append(fs.created, table)
+	return nil
+}
+
+func TestReprocessor_Run(t *testing.T) {
+	packet, err := parser.MakeCodec8Packet([]*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 25.451, Latitude: 31.654, Speed: 80, EventID: 12},
+	})
+	assert.NilError(t, err)
+	decoded, err := parser.ParsePacket(packet, "356307042441013")
+	assert.NilError(t, err)
+	filter := &db.RawFrameFilter{
+		IMEIs: []string{"356307042441013"},
+		From:  time.Now().Add(-time.Hour),
+		To:    time.Now(),
+	}
+	frames := []*db.RawFrame{
+		{IMEI: "356307042441013", Payload: packet},
+		{IMEI: "356307042441013", Payload: []byte{0, 0, 0, 0, 0, 0, 0, 3, 0x08, 1, 0, 0, 0, 0, 0}},
+	}
+
+	tests := map[string]struct {
+		opts        *Options
+		stored      []*pb.AVLData
+		wantResult  *Result
+		wantSaved   map[string]int
+		wantCreated []string
+		wantOutput  string
+		errWant     error
+	}{
+		"write to shadow table": {
+			opts:        &Options{Filter: filter, TargetTable: "avlpoints_shadow"},
+			wantResult:  &Result{Frames: 2, FailedFrames: 1, Points: 1},
+			wantSaved:   map[string]int{"avlpoints_shadow": 1},
+			wantCreated: []string{"avlpoints_shadow"},
+		},
+		"write to live table": {
+			opts:       &Options{Filter: filter},
+			wantResult: &Result{Frames: 2, FailedFrames: 1, Points: 1},
+			wantSaved:  map[string]int{db.AvlPointsTable: 1},
+		},
+		"dry run new point": {
+			opts:       &Options{Filter: filter, DryRun: true},
+			wantResult: &Result{Frames: 2, FailedFrames: 1, Points: 1, NewPoints: 1},
+			wantSaved:  map[string]int{},
+			wantOutput: "+ 356307042441013 " + decoded[0].GetTimestamp() + "\n",
+		},
+		"dry run changed point": {
+			opts: &Options{Filter: filter, DryRun: true},
+			stored: []*pb.AVLData{
+				{
+					Imei:      "356307042441013",
+					Timestamp: decoded[0].GetTimestamp(),
+					Priority:  pb.PacketPriority_PACKET_PRIORITY_HIGH,
+					EventId:   12,
+					Gps:       &pb.GPS{Longitude: 25.451, Latitude: 31.654, Speed: 70},
+				},
+			},
+			wantResult: &Result{Frames: 2, FailedFrames: 1, Points: 1, ChangedPoints: 1},
+			wantSaved:  map[string]int{},
+			wantOutput: "~ 356307042441013 " + decoded[0].GetTimestamp() + "\n    speed: 70 -> 80\n",
+		},
+		"no imei": {
+			opts:    &Options{Filter: &db.RawFrameFilter{From: filter.From, To: filter.To}},
+			errWant: ErrNoIMEI,
+		},
+		"invalid time range": {
+			opts:    &Options{Filter: &db.RawFrameFilter{IMEIs: filter.IMEIs, From: filter.To, To: filter.From}},
+			errWant: ErrInvalidTimeRange,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeStore{frames: frames, stored: test.stored, saved: map[string][]*pb.AVLData{}}
+			out := &bytes.Buffer{}
+			result, err := NewReprocessor(store, zap.NewNop(), out).Run(context.Background(), test.opts)
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, result, test.wantResult)
+			assert.Equal(t, len(store.saved), len(test.wantSaved))
+			for table, count := range test.wantSaved {
+				assert.Equal(t, len(store.saved[table]), count)
+			}
+			assert.DeepEqual(t, store.created, test.wantCreated)
+			assert.Equal(t, out.String(), test.wantOutput)
+		})
+	}
+}
+
+func TestReprocessor_RunBatches(t *testing.T) {
+	packet, err := parser.MakeCodec8Packet([]*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 25.451, Latitude: 31.654, Speed: 80, EventID: 12},
+	})
+	assert.NilError(t, err)
+	frames := make([]*db.RawFrame, 5)
+	for i := range frames {
+		frames[i] = &db.RawFrame{IMEI: "356307042441013", Payload: packet}
+	}
+	tests := map[string]struct {
+		batchSize int
+		wantSaves int
+	}{
+		"default batch size": {wantSaves: 1},
+		"batch of two":       {batchSize: 2, wantSaves: 3},
+		"batch per frame":    {batchSize: 1, wantSaves: 5},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeStore{frames: frames, saved: map[string][]*pb.AVLData{}}
+			result, err := NewReprocessor(store, zap.NewNop(), &bytes.Buffer{}).Run(context.Background(), &Options{
+				Filter: &db.RawFrameFilter{
+					IMEIs: []string{"356307042441013"},
+					From:  time.Now().Add(-time.Hour),
+					To:    time.Now(),
+				},
+				BatchSize: test.batchSize,
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, result.Points, uint64(5))
+			assert.Equal(t, store.saves, test.wantSaves)
+			assert.Equal(t, len(store.saved[db.AvlPointsTable]), 5)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/session"
+	"go.uber.org/zap"
+)
+
+var ErrDeviceDisconnected = errors.New("device disconnected before answering")
+
+// sendCommands writes queued GPRS commands of the session device to conn. Commands are sent after the
+// handshake and after data acks, when the device listens and no other write is in progress
+func (ts *TeltonikaServer) sendCommands(conn net.Conn, devSess *session.Session) error {
+	imei := devSess.IMEI()
+	for _, cmd := range ts.commands.Claim(imei, devSess.ID()) {
+		frame, err := parser.EncodeCommand(cmd.Text)
+		if err != nil {
+			ts.commands.MarkFailed(cmd.ID, err)
+			continue
+		}
+		if err := ts.write(conn, frame); err != nil {
+			ts.commands.MarkFailed(cmd.ID, err)
+			return err
+		}
+		ts.log.Info("command sent",
+			zap.String("imei", imei),
+			zap.Uint64("id", cmd.ID),
+			zap.String("command", cmd.Text),
+		)
+	}
+	return nil
+}
+
+// handleCommandResponse stores response of a codec 12 frame received on session, responses are not acked
+func (ts *TeltonikaServer) handleCommandResponse(devSess *session.Session, frame []byte) {
+	imei := devSess.IMEI()
+	response, err := parser.DecodeCommandResponse(frame)
+	if err != nil {
+		ts.log.Error("decode command response failed",
+			zap.Error(err),
+			zap.String("imei", imei),
+		)
+		return
+	}
+	cmd, answered := ts.commands.Answer(devSess.ID(), response)
+	if !answered {
+		ts.log.Warn("unexpected command response",
+			zap.String("imei", imei),
+			zap.String("response", response),
+		)
+		return
+	}
+	ts.log.Info("command answered",
+		zap.String("imei", imei),
+		zap.Uint64("id", cmd.ID),
+		zap.String("response", response),
+	)
+	if strings.EqualFold(strings.TrimSpace(cmd.Text), parser.CommandGetVer) {
+		if firmware, found := parser.FirmwareVersion(response); found {
+			devSess.SetFirmware(firmware)
+			ts.firmware.Store(imei, firmware)
+		}
+	}
+}
+
+// initFirmware sets firmware of a new session when it is known from a previous session,
+// otherwise getver is queued when firmware query is enabled
+func (ts *TeltonikaServer) initFirmware(devSess *session.Session) {
+	if firmware, found := ts.firmware.Load(devSess.IMEI()); found {
+		devSess.SetFirmware(firmware.(string))
+		return
+	}
+	if !ts.queryFirmware {
+		return
+	}
+	if _, err := ts.commands.Queue(devSess.IMEI(), parser.CommandGetVer); err != nil {
+		ts.log.Warn("queue firmware query failed",
+			zap.String("imei", devSess.IMEI()),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/irisco88/teltonika-device/command"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func readCommand(t *testing.T, clientConn net.Conn, text string) {
+	want, err := parser.EncodeCommand(text)
+	assert.NilError(t, err)
+	frame, err := parser.ReadFrame(clientConn)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, frame, want)
+}
+
+func TestCommands(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	ctrl := gomock.NewController(t)
+	dbConn := mockdb.NewMockAVLDBConn(ctrl)
+	dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+	const imei = "356478954125694"
+
+	server := NewServer("", zap.NewNop(), natsClient, dbConn).(*TeltonikaServer)
+	getinfo, err := server.Commands().Queue(imei, "getinfo")
+	assert.NilError(t, err)
+	clientConn, serverConn := net.Pipe()
+	server.wg.Add(1)
+	go server.HandleConnection(serverConn)
+
+	// commands queued while offline are sent after the handshake
+	ImeiAuthenticate(t, clientConn, imei)
+	readCommand(t, clientConn, getinfo.Text)
+	_, err = clientConn.Write(parser.EncodeCommandResponse("RTC:2023/9/27 7:33 Init:2023/9/27 7:30"))
+	assert.NilError(t, err)
+	for i := 0; i < 100 && server.Commands().List(imei)[0].Status != command.StatusAnswered; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, server.Commands().List(imei)[0].Status, command.StatusAnswered)
+	assert.Equal(t, server.Commands().List(imei)[0].Response, "RTC:2023/9/27 7:33 Init:2023/9/27 7:30")
+
+	// commands queued while connected are sent after next data ack
+	getver, err := server.Commands().Queue(imei, "getver")
+	assert.NilError(t, err)
+	SendPoints(t, clientConn, []*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7},
+	})
+	readCommand(t, clientConn, getver.Text)
+	sessInfo, found := server.Sessions().Get(imei)
+	assert.Assert(t, found)
+	assert.Equal(t, len(sessInfo.LastPacket()), 1)
+
+	// getver responses set firmware of the session
+	_, err = clientConn.Write(parser.EncodeCommandResponse("Ver:03.27.07_04 GPS:AXN_5.10_3333 Hw:FMB920 Mod:13"))
+	assert.NilError(t, err)
+	for i := 0; i < 100 && sessInfo.Info().Firmware == ""; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, sessInfo.Info().Firmware, "03.27.07_04")
+
+	// unanswered commands fail on disconnect
+	getgps, err := server.Commands().Queue(imei, "getgps")
+	assert.NilError(t, err)
+	SendPoints(t, clientConn, []*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7},
+	})
+	readCommand(t, clientConn, getgps.Text)
+	clientConn.Close()
+	server.wg.Wait()
+	commands := server.Commands().List(imei)
+	assert.Equal(t, commands[0].ID, getgps.ID)
+	assert.Equal(t, commands[0].Status, command.StatusFailed)
+	assert.Equal(t, commands[0].Error, ErrDeviceDisconnected.Error())
+}
+
+func TestFirmwareQuery(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	ctrl := gomock.NewController(t)
+	dbConn := mockdb.NewMockAVLDBConn(ctrl)
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+	const imei = "356478954125694"
+
+	server := NewServer("", zap.NewNop(), natsClient, dbConn, WithFirmwareQuery()).(*TeltonikaServer)
+	connect := func() net.Conn {
+		clientConn, serverConn := net.Pipe()
+		server.wg.Add(1)
+		go server.HandleConnection(serverConn)
+		ImeiAuthenticate(t, clientConn, imei)
+		return clientConn
+	}
+
+	// firmware is queried once and remembered for later sessions
+	clientConn := connect()
+	readCommand(t, clientConn, parser.CommandGetVer)
+	_, err := clientConn.Write(parser.EncodeCommandResponse("Ver:03.27.07_04 GPS:AXN_5.10_3333 Hw:FMB920"))
+	assert.NilError(t, err)
+	for i := 0; i < 100 && server.Commands().List(imei)[0].Status != command.StatusAnswered; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	clientConn.Close()
+	server.wg.Wait()
+
+	clientConn = connect()
+	defer clientConn.Close()
+	sess, found := server.Sessions().Get(imei)
+	assert.Assert(t, found)
+	assert.Equal(t, sess.Info().Firmware, "03.27.07_04")
+	assert.Equal(t, len(server.Commands().List(imei)), 1)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultShutdownTimeout bounds draining of Stop
+const DefaultShutdownTimeout = time.Second * 30
+
+// closeWaitTimeout bounds waiting for packets being stored after connections are closed forcibly
+const closeWaitTimeout = time.Second * 5
+
+// beginWork adds a connection or udp packet to the drained work,
+// it returns false when server is already shutting down
+func (ts *TeltonikaServer) beginWork() bool {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	if ts.draining {
+		return false
+	}
+	ts.wg.Add(1)
+	return true
+}
+
+// trackConn registers conn for draining, it returns false when server is already shutting down
+func (ts *TeltonikaServer) trackConn(conn net.Conn) bool {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	if ts.draining {
+		return false
+	}
+	ts.conns[conn] = Empty{}
+	return true
+}
+
+func (ts *TeltonikaServer) untrackConn(conn net.Conn) {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	delete(ts.conns, conn)
+}
+
+// beginRead sets read deadline of conn before waiting for device data,
+// it returns false when server is shutting down so no new packet is read
+func (ts *TeltonikaServer) beginRead(conn net.Conn, timeout time.Duration) bool {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	if ts.draining {
+		return false
+	}
+	ts.setReadDeadline(conn, timeout)
+	return true
+}
+
+func (ts *TeltonikaServer) isDraining() bool {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	return ts.draining
+}
+
+// startDrain stops reading on every tracked connection, connections handling a packet finish it first
+func (ts *TeltonikaServer) startDrain() {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	ts.draining = true
+	now := time.Now()
+	for conn := range ts.conns {
+		if err := conn.SetReadDeadline(now); err != nil {
+			ts.log.Debug("set read deadline failed", zap.Error(err))
+		}
+	}
+	if ts.udpConn != nil {
+		if err := ts.udpConn.SetReadDeadline(now); err != nil {
+			ts.log.Debug("set udp read deadline failed", zap.Error(err))
+		}
+	}
+}
+
+// closeConns closes every tracked connection, used when draining times out
+func (ts *TeltonikaServer) closeConns() {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	for conn := range ts.conns {
+		conn.Close()
+	}
+}
+
+// Shutdown stops accepting devices and waits until in-flight packets are stored and acked and
+// connections are closed. Connections are closed forcibly and ctx error is returned when ctx is done first,
+// packets being stored are then awaited a few seconds more and may still be written after Shutdown returns
+func (ts *TeltonikaServer) Shutdown(ctx context.Context) error {
+	var err error
+	ts.stopOnce.Do(func() {
+		ts.log.Info("stopping server")
+		// Close the listeners to stop accepting new connections
+		if ts.ln != nil {
+			ts.ln.Close()
+		}
+		if ts.tlsLn != nil {
+			ts.tlsLn.Close()
+		}
+		ts.startDrain()
+
+		done := make(chan Empty)
+		go func() {
+			ts.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			ts.log.Info("connections drained")
+		case <-ctx.Done():
+			ts.connsMu.Lock()
+			remaining := len(ts.conns)
+			ts.connsMu.Unlock()
+			ts.log.Warn("drain timed out, closing connections", zap.Int("connections", remaining))
+			ts.closeConns()
+			err = ctx.Err()
+			select {
+			case <-done:
+			case <-time.After(closeWaitTimeout):
+				ts.log.Warn("packets are still being stored after shutdown")
+			}
+		}
+		// udp conn is closed after draining so in-flight udp packets are still acked
+		if ts.udpConn != nil {
+			ts.udpConn.Close()
+		}
+		if ts.natsConn != nil {
+			if e := ts.natsConn.FlushTimeout(time.Second * 5); e != nil {
+				ts.log.Error("flush nats failed", zap.Error(e))
+			}
+		}
+		close(ts.quitChan)
+	})
+	return err
+}
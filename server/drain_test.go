@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
+	"github.com/irisco88/teltonika-device/parser"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	const imei = "356478954125694"
+	points := []*parser.AVLData{{Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7, Speed: 40}}
+	tests := map[string]struct {
+		// sendPoints sends a packet whose storing is blocked until shutdown starts
+		sendPoints bool
+		timeout    time.Duration
+		wantAck    bool
+		errWant    error
+	}{
+		"idle session": {
+			timeout: time.Second * 5,
+		},
+		"in-flight packet": {
+			sendPoints: true,
+			timeout:    time.Second * 5,
+			wantAck:    true,
+		},
+		"drain timeout": {
+			sendPoints: true,
+			timeout:    time.Millisecond * 100,
+			errWant:    context.DeadlineExceeded,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			dbConn := mockdb.NewMockAVLDBConn(ctrl)
+			saving, release := make(chan Empty), make(chan Empty)
+			if test.sendPoints {
+				dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).Return(nil)
+				dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Len(1)).DoAndReturn(
+					func(context.Context, interface{}) error {
+						close(saving)
+						<-release
+						return nil
+					})
+			}
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			server := NewServer("", zap.NewNop(), natsClient, dbConn).(*TeltonikaServer)
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+			server.wg.Add(1)
+			go server.HandleConnection(serverConn)
+			ImeiAuthenticate(t, clientConn, imei)
+
+			ackChan := make(chan []byte, 1)
+			if test.sendPoints {
+				packet, err := parser.MakeCodec8Packet(points)
+				assert.NilError(t, err)
+				_, err = clientConn.Write(packet)
+				assert.NilError(t, err)
+				go func() {
+					buf := make([]byte, 4)
+					if _, err := io.ReadFull(clientConn, buf); err != nil {
+						close(ackChan)
+						return
+					}
+					ackChan <- buf
+				}()
+				<-saving
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.timeout)
+			defer cancel()
+			shutdownErr := make(chan error, 1)
+			go func() {
+				shutdownErr <- server.Shutdown(ctx)
+			}()
+			if test.sendPoints {
+				// storing finishes only after shutdown started, unless drain times out first
+				if test.errWant != nil {
+					// packet being stored is awaited after connections are closed
+					select {
+					case <-shutdownErr:
+						t.Fatal("shutdown returned while a packet was being stored")
+					case <-time.After(test.timeout * 2):
+					}
+					_, ok := <-ackChan
+					assert.Assert(t, !ok)
+					close(release)
+					assert.ErrorIs(t, <-shutdownErr, test.errWant)
+					return
+				}
+				time.Sleep(time.Millisecond * 50)
+				close(release)
+				assert.DeepEqual(t, <-ackChan, []byte{0, 0, 0, 1})
+			}
+			assert.NilError(t, <-shutdownErr)
+			_, err := clientConn.Read(make([]byte, 1))
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, server.Sessions().Len(), 0)
+		})
+	}
+}
+
+func TestStartReady(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer busy.Close()
+	server := NewServer(busy.Addr().String(), zap.NewNop(), natsClient, nil).(*TeltonikaServer)
+	assert.ErrorContains(t, server.Start(), "failed to listen")
+	assert.ErrorIs(t, server.Ready(), ErrNotListening)
+
+	server = NewServer("", zap.NewNop(), natsClient, nil).(*TeltonikaServer)
+	assert.ErrorIs(t, server.Start(), ErrNoListener)
+
+	server = NewServer("127.0.0.1:0", zap.NewNop(), natsClient, nil).(*TeltonikaServer)
+	started := make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+	assert.Assert(t, waitReady(server))
+	server.Stop()
+	assert.NilError(t, <-started)
+	assert.ErrorIs(t, server.Ready(), ErrShuttingDown)
+}
+
+func waitReady(server *TeltonikaServer) bool {
+	for i := 0; i < 100; i++ {
+		if server.Ready() == nil {
+			return true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return false
+}
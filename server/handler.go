@@ -1,122 +1,367 @@
 package server
 
 import (
+	"bufio"
 	"context"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/db"
 	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/registry"
+	"github.com/irisco88/teltonika-device/session"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/proto"
 	"io"
 	"net"
+	"time"
+)
+
+// connection close reasons recorded in logs and metrics
+const (
+	CloseReasonEOF              = "eof"
+	CloseReasonClosed           = "closed"
+	CloseReasonHandshakeTimeout = "handshake_timeout"
+	CloseReasonIdleTimeout      = "idle_timeout"
+	CloseReasonReadError        = "read_error"
+	CloseReasonWriteTimeout     = "write_timeout"
+	CloseReasonWriteError       = "write_error"
+	CloseReasonInvalidIMEI      = "invalid_imei"
+	CloseReasonRejected         = "rejected"
+	CloseReasonRegistryError    = "registry_error"
+	CloseReasonDuplicate        = "duplicate"
+	CloseReasonParseError       = "parse_error"
+	CloseReasonShutdown         = "shutdown"
 )
 
 func (ts *TeltonikaServer) HandleConnection(conn net.Conn) {
-	defer conn.Close()
 	defer ts.wg.Done()
-	authenticated := false
-	var imei string
-	for {
-		// Make a buffer to hold incoming data.
-		buf := make([]byte, 2048)
-
-		// Read the incoming connection into the buffer.
-		size, err := conn.Read(buf)
-		if err != nil {
-			if !errors.Is(err, io.EOF) {
-				ts.log.Error("read failed", zap.Error(err))
+	var (
+		imei    string
+		devSess *session.Session
+		reason  string
+		err     error
+	)
+	defer func() {
+		conn.Close()
+		closedConnectionsTotal.WithLabelValues(reason).Inc()
+		switch reason {
+		// registry errors are not counted, an unavailable registry must not ban every device
+		case CloseReasonHandshakeTimeout, CloseReasonInvalidIMEI, CloseReasonRejected:
+			if ts.limiter.handshakeFailed(remoteIP(conn)) {
+				ts.log.Warn("ip banned after failed handshakes",
+					zap.String("ip", remoteIP(conn)),
+					zap.Duration("duration", ts.limits.BanDuration),
+				)
 			}
-			return
 		}
-		if !authenticated {
-			imei, err = parser.DecodeIMEI(buf[:size])
-			if err != nil {
-				ts.log.Error("decode imei failed", zap.Error(err))
-				return
-			}
-			ts.log.Info("Data received",
-				zap.String("ip", conn.RemoteAddr().String()),
-				zap.Int("size", size),
+		ts.log.Info("connection closed",
+			zap.String("ip", conn.RemoteAddr().String()),
+			zap.String("imei", imei),
+			zap.String("reason", reason),
+			zap.NamedError("cause", err),
+		)
+	}()
+	if !ts.trackConn(conn) {
+		reason = CloseReasonShutdown
+		return
+	}
+	defer ts.untrackConn(conn)
+	activeConnections.Inc()
+	defer activeConnections.Dec()
+	reader := bufio.NewReader(conn)
+	handshakeCtx, cancelHandshake := ts.handshakeContext()
+	defer cancelHandshake()
+
+	// Make a buffer to hold incoming data.
+	buf := make([]byte, 2048)
+	if !ts.beginRead(conn, ts.timeouts.Handshake) {
+		reason = CloseReasonShutdown
+		return
+	}
+	// Read the incoming connection into the buffer.
+	size, err := reader.Read(buf)
+	if err != nil {
+		reason = ts.readCloseReason(err, CloseReasonHandshakeTimeout)
+		return
+	}
+	imei, err = parser.DecodeIMEIWithMode(buf[:size], ts.imeiMode)
+	if err != nil {
+		rejectedDevicesTotal.WithLabelValues("invalid_imei").Inc()
+		handshakesTotal.WithLabelValues(HandshakeDeclined, "invalid_imei").Inc()
+		ts.log.Error("decode imei failed",
+			zap.Error(err),
+			zap.String("ip", conn.RemoteAddr().String()),
+		)
+		reason = CloseReasonInvalidIMEI
+		_ = ts.ResponseDecline(conn)
+		return
+	}
+	ts.log.Info("Data received",
+		zap.String("ip", conn.RemoteAddr().String()),
+		zap.Int("size", size),
+		zap.String("imei", imei),
+	)
+	if ts.auth != nil {
+		if e := ts.auth.Authenticate(handshakeCtx, imei); e != nil {
+			rejectReason := registry.Reason(e)
+			rejectedDevicesTotal.WithLabelValues(rejectReason).Inc()
+			handshakesTotal.WithLabelValues(HandshakeDeclined, rejectReason).Inc()
+			ts.log.Warn("device rejected",
 				zap.String("imei", imei),
+				zap.String("reason", rejectReason),
+				zap.String("ip", conn.RemoteAddr().String()),
+				zap.Error(e),
 			)
-			ts.ResponseAcceptIMEI(conn)
-			authenticated = true
+			reason, err = CloseReasonRejected, e
+			if !registry.Rejected(e) {
+				reason = CloseReasonRegistryError
+			}
+			_ = ts.ResponseDecline(conn)
+			return
+		}
+	}
+	devSess = session.New(imei, conn)
+	if !ts.registerSession(devSess) {
+		reason = CloseReasonDuplicate
+		handshakesTotal.WithLabelValues(HandshakeDeclined, CloseReasonDuplicate).Inc()
+		_ = ts.ResponseDecline(conn)
+		return
+	}
+	defer ts.sessions.Remove(devSess)
+	defer ts.commands.FailSent(devSess.ID(), ErrDeviceDisconnected)
+	ts.limiter.handshakeSucceeded(remoteIP(conn))
+	handshakesTotal.WithLabelValues(HandshakeAccepted, "").Inc()
+	ts.initFirmware(devSess)
+	if err = ts.ResponseAcceptIMEI(conn); err != nil {
+		reason = writeCloseReason(err)
+		return
+	}
+	if err = ts.sendCommands(conn, devSess); err != nil {
+		reason = writeCloseReason(err)
+		return
+	}
+
+	for {
+		if !ts.beginRead(conn, ts.timeouts.Idle) {
+			reason = CloseReasonShutdown
+			return
+		}
+		frame, readErr := parser.ReadFrame(reader)
+		if readErr != nil {
+			reason, err = ts.readCloseReason(readErr, CloseReasonIdleTimeout), readErr
+			return
+		}
+		if parser.FrameCodecID(frame) == parser.CodecCommand {
+			devSess.RecordFrame(len(frame), 0, parser.CodecCommand)
+			ts.handleCommandResponse(devSess, frame)
 			continue
 		}
-		ctx := context.Background()
+		receivedAt := time.Now()
+		ctx, span := startFrameSpan(TransportTCP, imei)
+		points, parseErr := ts.processFrame(ctx, TransportTCP, imei, conn.RemoteAddr().String(), frame)
+		devSess.RecordFrame(len(frame), len(points), parser.FrameCodecID(frame))
+		if parseErr != nil {
+			endSpan(span, parseErr)
+			reason, err = CloseReasonParseError, parseErr
+			return
+		}
+		err = ts.ResponseAcceptDataPack(conn, len(points))
+		endSpan(span, err)
+		if err != nil {
+			reason = writeCloseReason(err)
+			return
+		}
+		ackLatency.WithLabelValues(TransportTCP).Observe(time.Since(receivedAt).Seconds())
+		devSess.SetLastPacket(points)
+		if err = ts.sendCommands(conn, devSess); err != nil {
+			reason = writeCloseReason(err)
+			return
+		}
+	}
+}
+
+// processFrame archives raw frame, parses it and delivers points to nats, avl database and sinks,
+// ctx carries span of the frame
+func (ts *TeltonikaServer) processFrame(ctx context.Context, transport, imei, remoteAddr string, frame []byte) ([]*pb.AVLData, error) {
+	receivedAt := time.Now()
 
+	_, parseSpan := tracer.Start(ctx, "parse")
+	points, parseErr := parser.ParsePacket(frame, imei)
+	endSpan(parseSpan, parseErr)
+	rawFrame := &db.RawFrame{
+		IMEI:        imei,
+		ReceivedAt:  receivedAt,
+		RemoteAddr:  remoteAddr,
+		CodecID:     parser.FrameCodecID(frame),
+		RecordCount: parser.FrameRecordCount(frame),
+		CRCValid:    parser.VerifyCRC(frame),
+		Payload:     frame,
+	}
+	codec := parser.CodecName(rawFrame.CodecID)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attrCodec.String(codec),
+		attrRecordCount.Int(int(rawFrame.RecordCount)),
+	)
+	packetsTotal.WithLabelValues(transport, codec).Inc()
+	if !rawFrame.CRCValid {
+		crcFailuresTotal.WithLabelValues(transport).Inc()
+	}
+	if parseErr != nil {
+		rawFrame.ParseError = parseErr.Error()
+		parseErrorsTotal.WithLabelValues(parseErrorType(parseErr)).Inc()
+	}
+	// avl database is optional when points are only written to sinks
+	if ts.avlDB != nil {
+		ts.wg.Add(1)
 		go func() {
-			if rawDataErr := ts.avlDB.SaveRawData(ctx, imei, hex.EncodeToString(buf)); rawDataErr != nil {
+			defer ts.wg.Done()
+			if rawDataErr := observeInsert(ctx, dbOpSaveRawData, func(ctx context.Context) error {
+				return ts.avlDB.SaveRawData(ctx, rawFrame)
+			}); rawDataErr != nil {
 				ts.log.Error("save raw data failed", zap.Error(rawDataErr))
-			} else {
-				ts.log.Info("rawData:",
-					zap.Any("raw:", hex.EncodeToString(buf)),
-				)
 			}
 		}()
-
-		points, err := parser.ParsePacket(buf, imei)
-		if err != nil {
-			ts.log.Error("Error while parsing data",
-				zap.Error(err),
-				zap.String("imei", imei),
-			)
-			return
-		}
-		//	go func() {
-		ts.LogPoints(points, buf)
-		ts.PublishLastPoint(imei, points)
-		if e := ts.avlDB.SaveAvlPoints(ctx, points); e != nil {
+	}
+	if parseErr != nil {
+		ts.log.Error("Error while parsing data",
+			zap.Error(parseErr),
+			zap.String("imei", imei),
+		)
+		return nil, parseErr
+	}
+	recordsTotal.WithLabelValues(transport, codec).Add(float64(len(points)))
+	ts.LogPoints(points)
+	ts.PublishPoints(ctx, imei, points)
+	ts.PublishLastPoint(ctx, imei, points)
+	if ts.avlDB != nil {
+		if e := observeInsert(ctx, dbOpSavePoints, func(ctx context.Context) error {
+			return ts.avlDB.SaveAvlPoints(ctx, points)
+		}); e != nil {
 			ts.log.Error("failed to save avl points", zap.Error(e))
 		}
-		//}()
-		ts.ResponseAcceptDataPack(conn, len(points))
 	}
+	for _, sink := range ts.sinks {
+		if e := observeInsert(ctx, dbOpSinkPoints, func(ctx context.Context) error {
+			return sink.SaveAvlPoints(ctx, points)
+		}); e != nil {
+			ts.log.Error("failed to write avl points to sink", zap.Error(e))
+		}
+	}
+	return points, nil
 }
 
-func (ts *TeltonikaServer) PublishLastPoint(imei string, points []*pb.AVLData) {
-	subject := fmt.Sprintf("device.lastpoint.%s", imei)
-	lastPointByte, err := proto.Marshal(points[len(points)-1])
-	if err != nil {
-		ts.log.Error("marshal last point failed", zap.Error(err))
+// handshakeContext limits registry lookups to the handshake timeout, so a slow registry cannot hold devices
+func (ts *TeltonikaServer) handshakeContext() (context.Context, context.CancelFunc) {
+	if ts.timeouts.Handshake <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), ts.timeouts.Handshake)
+}
+
+// setReadDeadline sets read deadline timeout from now, zero timeout disables deadline
+func (ts *TeltonikaServer) setReadDeadline(conn net.Conn, timeout time.Duration) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		ts.log.Debug("set read deadline failed", zap.Error(err))
+	}
+}
+
+// write writes response to device within write timeout
+func (ts *TeltonikaServer) write(conn net.Conn, data []byte) error {
+	var deadline time.Time
+	if ts.timeouts.Write > 0 {
+		deadline = time.Now().Add(ts.timeouts.Write)
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		ts.log.Debug("set write deadline failed", zap.Error(err))
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+func (ts *TeltonikaServer) readCloseReason(err error, timeoutReason string) string {
+	var netErr net.Error
+	switch {
+	case ts.isDraining():
+		// reads are interrupted by shutdown
+		return CloseReasonShutdown
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return CloseReasonEOF
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return timeoutReason
+	case errors.Is(err, net.ErrClosed), errors.Is(err, io.ErrClosedPipe):
+		return CloseReasonClosed
+	}
+	return CloseReasonReadError
+}
+
+func writeCloseReason(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return CloseReasonWriteTimeout
+	}
+	return CloseReasonWriteError
+}
+
+// PublishLastPoint publishes the newest point by timestamp to nats with trace context of ctx in message headers,
+// points older than the last point already published for imei are skipped
+func (ts *TeltonikaServer) PublishLastPoint(ctx context.Context, imei string, points []*pb.AVLData) {
+	lastPoint := parser.NewestPoint(points)
+	if lastPoint == nil || !ts.advanceLastPoint(imei, lastPoint) {
 		return
 	}
-	if e := ts.natsConn.Publish(subject, lastPointByte); e != nil {
-		ts.log.Error("publish last point failed", zap.Error(e))
+	subject := fmt.Sprintf(LastPointSubject, imei)
+	if err := ts.publish(ctx, subject, natsSubjectLastPoint, ContentTypeAVLData, lastPoint); err != nil {
+		ts.log.Error("publish last point failed", zap.Error(err))
+	}
+}
+
+// advanceLastPoint records point as the newest last point of imei, it returns false when a newer point
+// was published before, e.g. stored records uploaded after a coverage gap
+func (ts *TeltonikaServer) advanceLastPoint(imei string, point *pb.AVLData) bool {
+	timestamp, err := parser.ParseTimestamp(point.GetTimestamp())
+	if err != nil {
+		return true
 	}
+	return ts.lastPoints.advance(imei, timestamp)
 }
 
-func (ts *TeltonikaServer) LogPoints(points []*pb.AVLData, buff []byte) {
+// LogPoints logs decoded points at debug level, they are logged for devices in debug mode too
+func (ts *TeltonikaServer) LogPoints(points []*pb.AVLData) {
 	for _, p := range points {
-		ts.log.Info("new packet",
-			zap.String("Priority", p.Priority.String()),
-			zap.String("IMEI", p.GetImei()),
-			//zap.Uint64("Timestamp", p.GetTimestamp()),
-			zap.Any("Gps", p.GetGps()),
-			zap.Any("IOElements", p.GetIoElements()),
-			zap.Any("***************", buff),
+		ts.log.Debug("new packet",
+			zap.String("imei", p.GetImei()),
+			zap.String("timestamp", p.GetTimestamp()),
+			zap.String("priority", p.GetPriority().String()),
+			zap.Any("gps", p.GetGps()),
+			zap.Any("io_elements", p.GetIoElements()),
 		)
 	}
 }
 
-func (ts *TeltonikaServer) ResponseAcceptIMEI(conn net.Conn) {
-	_, err := conn.Write([]byte{1})
+func (ts *TeltonikaServer) ResponseAcceptIMEI(conn net.Conn) error {
+	err := ts.write(conn, []byte{1})
 	if err != nil {
 		ts.log.Error("response accept imei failed", zap.Error(err))
 	}
+	return err
 }
-func (ts *TeltonikaServer) ResponseAcceptDataPack(conn net.Conn, pointLen int) {
-	_, err := conn.Write([]byte{0, 0, 0, uint8(pointLen)})
+func (ts *TeltonikaServer) ResponseAcceptDataPack(conn net.Conn, pointLen int) error {
+	err := ts.write(conn, []byte{0, 0, 0, uint8(pointLen)})
 	if err != nil {
 		ts.log.Error("response accept avl data failed", zap.Error(err))
 	}
+	return err
 }
 
-func (ts *TeltonikaServer) ResponseDecline(conn net.Conn) {
-	_, err := conn.Write([]byte{0})
+func (ts *TeltonikaServer) ResponseDecline(conn net.Conn) error {
+	err := ts.write(conn, []byte{0})
 	if err != nil {
 		ts.log.Error("response decline ailed", zap.Error(err))
 	}
+	return err
 }
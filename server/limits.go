@@ -0,0 +1,201 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// connection refuse reasons recorded in logs and metrics
+const (
+	RefuseReasonMaxConnections = "max_connections"
+	RefuseReasonIPConnections  = "ip_connections"
+	RefuseReasonRateLimited    = "rate_limited"
+	RefuseReasonBanned         = "banned"
+)
+
+const (
+	ipStatePruneInterval         = time.Minute
+	ipStateIdleTimeout           = time.Minute * 10
+	defaultConnectionBurstFactor = 2
+)
+
+// Limits of accepted connections, zero disables a limit
+type Limits struct {
+	// MaxConnections limits connections of all devices
+	MaxConnections int
+	// MaxConnectionsPerIP limits connections of a source ip
+	MaxConnectionsPerIP int
+	// ConnectionRate limits new connections and udp packets per second of a source ip with a token bucket
+	ConnectionRate float64
+	// ConnectionBurst is size of token bucket, twice ConnectionRate when zero
+	ConnectionBurst int
+	// MaxHandshakeFailures bans a source ip after this many consecutive failed IMEI handshakes
+	MaxHandshakeFailures int
+	// BanDuration is how long a source ip is banned
+	BanDuration time.Duration
+	// MaxUDPPackets limits udp packets handled at once, further packets are dropped
+	MaxUDPPackets int
+}
+
+// DefaultLimits are used unless WithLimits is given
+var DefaultLimits = Limits{
+	MaxConnections:       20000,
+	MaxHandshakeFailures: 10,
+	BanDuration:          time.Minute * 10,
+	MaxUDPPackets:        1024,
+}
+
+type ipState struct {
+	conns       int
+	bucket      *rate.Limiter
+	failures    int
+	bannedUntil time.Time
+	lastSeen    time.Time
+}
+
+// connLimiter applies Limits to accepted connections by source ip
+type connLimiter struct {
+	mu        sync.Mutex
+	limits    Limits
+	total     int
+	ips       map[string]*ipState
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func newConnLimiter(limits Limits) *connLimiter {
+	return &connLimiter{
+		limits: limits,
+		ips:    make(map[string]*ipState),
+		now:    time.Now,
+	}
+}
+
+// allow reserves a connection of ip, it returns refuse reason when connection must be closed
+func (cl *connLimiter) allow(ip string) (string, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	now := cl.now()
+	cl.prune(now)
+	state := cl.state(ip, now)
+	switch {
+	case now.Before(state.bannedUntil):
+		return RefuseReasonBanned, false
+	case cl.limits.MaxConnections > 0 && cl.total >= cl.limits.MaxConnections:
+		return RefuseReasonMaxConnections, false
+	case cl.limits.MaxConnectionsPerIP > 0 && state.conns >= cl.limits.MaxConnectionsPerIP:
+		return RefuseReasonIPConnections, false
+	case state.bucket != nil && !state.bucket.AllowN(now, 1):
+		return RefuseReasonRateLimited, false
+	}
+	cl.total++
+	state.conns++
+	return "", true
+}
+
+// allowPacket checks ban and rate of ip for a udp packet, it returns refuse reason when packet must be dropped.
+// Packets reserve no connection, state is kept only for rate limited or failing ips
+func (cl *connLimiter) allowPacket(ip string) (string, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	now := cl.now()
+	cl.prune(now)
+	if _, found := cl.ips[ip]; !found && cl.limits.ConnectionRate <= 0 {
+		return "", true
+	}
+	state := cl.state(ip, now)
+	switch {
+	case now.Before(state.bannedUntil):
+		return RefuseReasonBanned, false
+	case state.bucket != nil && !state.bucket.AllowN(now, 1):
+		return RefuseReasonRateLimited, false
+	}
+	return "", true
+}
+
+// release frees a connection reserved by allow
+func (cl *connLimiter) release(ip string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.total--
+	if state, found := cl.ips[ip]; found {
+		state.conns--
+		state.lastSeen = cl.now()
+	}
+}
+
+// handshakeFailed counts a failed IMEI handshake of ip and bans it after MaxHandshakeFailures
+func (cl *connLimiter) handshakeFailed(ip string) bool {
+	if cl.limits.MaxHandshakeFailures <= 0 || cl.limits.BanDuration <= 0 {
+		return false
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	now := cl.now()
+	state := cl.state(ip, now)
+	state.failures++
+	if state.failures < cl.limits.MaxHandshakeFailures {
+		return false
+	}
+	state.failures = 0
+	state.bannedUntil = now.Add(cl.limits.BanDuration)
+	return true
+}
+
+// handshakeSucceeded resets failed handshakes of ip
+func (cl *connLimiter) handshakeSucceeded(ip string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if state, found := cl.ips[ip]; found {
+		state.failures = 0
+	}
+}
+
+func (cl *connLimiter) state(ip string, now time.Time) *ipState {
+	state, found := cl.ips[ip]
+	if !found {
+		state = &ipState{}
+		if cl.limits.ConnectionRate > 0 {
+			burst := cl.limits.ConnectionBurst
+			if burst <= 0 {
+				burst = int(cl.limits.ConnectionRate*defaultConnectionBurstFactor) + 1
+			}
+			state.bucket = rate.NewLimiter(rate.Limit(cl.limits.ConnectionRate), burst)
+		}
+		cl.ips[ip] = state
+	}
+	state.lastSeen = now
+	return state
+}
+
+// prune forgets idle ips without connections, failures or bans
+func (cl *connLimiter) prune(now time.Time) {
+	if now.Sub(cl.lastPrune) < ipStatePruneInterval {
+		return
+	}
+	cl.lastPrune = now
+	for ip, state := range cl.ips {
+		if state.conns == 0 && state.failures == 0 && now.After(state.bannedUntil) &&
+			now.Sub(state.lastSeen) > ipStateIdleTimeout {
+			delete(cl.ips, ip)
+		}
+	}
+}
+
+// remoteIP returns source ip of connection without port
+func remoteIP(conn net.Conn) string {
+	return addrIP(conn.RemoteAddr())
+}
+
+// addrIP returns ip of addr without port
+func addrIP(addr net.Addr) string {
+	value := addr.String()
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		return value
+	}
+	return host
+}
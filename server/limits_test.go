@@ -0,0 +1,127 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func newTestLimiter(limits Limits) (*connLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)}
+	limiter := newConnLimiter(limits)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestConnLimiter_Connections(t *testing.T) {
+	limiter, _ := newTestLimiter(Limits{MaxConnections: 3, MaxConnectionsPerIP: 2})
+	for _, ip := range []string{"10.0.0.1", "10.0.0.1"} {
+		_, ok := limiter.allow(ip)
+		assert.Assert(t, ok)
+	}
+	reason, ok := limiter.allow("10.0.0.1")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonIPConnections)
+
+	_, ok = limiter.allow("10.0.0.2")
+	assert.Assert(t, ok)
+	reason, ok = limiter.allow("10.0.0.3")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonMaxConnections)
+
+	limiter.release("10.0.0.1")
+	_, ok = limiter.allow("10.0.0.3")
+	assert.Assert(t, ok)
+}
+
+func TestConnLimiter_Rate(t *testing.T) {
+	limiter, clock := newTestLimiter(Limits{ConnectionRate: 1, ConnectionBurst: 2})
+	for i := 0; i < 2; i++ {
+		_, ok := limiter.allow("10.0.0.1")
+		assert.Assert(t, ok)
+		limiter.release("10.0.0.1")
+	}
+	reason, ok := limiter.allow("10.0.0.1")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonRateLimited)
+	// other ips have their own bucket
+	_, ok = limiter.allow("10.0.0.2")
+	assert.Assert(t, ok)
+
+	clock.now = clock.now.Add(time.Second)
+	_, ok = limiter.allow("10.0.0.1")
+	assert.Assert(t, ok)
+}
+
+func TestConnLimiter_Ban(t *testing.T) {
+	limiter, clock := newTestLimiter(Limits{MaxHandshakeFailures: 3, BanDuration: time.Minute})
+	assert.Assert(t, !limiter.handshakeFailed("10.0.0.1"))
+	assert.Assert(t, !limiter.handshakeFailed("10.0.0.1"))
+	// successful handshake resets failures
+	limiter.handshakeSucceeded("10.0.0.1")
+	for i := 0; i < 2; i++ {
+		assert.Assert(t, !limiter.handshakeFailed("10.0.0.1"))
+	}
+	assert.Assert(t, limiter.handshakeFailed("10.0.0.1"))
+
+	reason, ok := limiter.allow("10.0.0.1")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonBanned)
+	_, ok = limiter.allow("10.0.0.2")
+	assert.Assert(t, ok)
+
+	clock.now = clock.now.Add(time.Minute + time.Second)
+	_, ok = limiter.allow("10.0.0.1")
+	assert.Assert(t, ok)
+}
+
+func TestConnLimiter_Packets(t *testing.T) {
+	limiter, _ := newTestLimiter(Limits{MaxHandshakeFailures: 1, BanDuration: time.Minute})
+	// packets of unknown ips keep no state without rate limit
+	_, ok := limiter.allowPacket("10.0.0.1")
+	assert.Assert(t, ok)
+	assert.Equal(t, len(limiter.ips), 0)
+
+	assert.Assert(t, limiter.handshakeFailed("10.0.0.1"))
+	reason, ok := limiter.allowPacket("10.0.0.1")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonBanned)
+
+	limiter, _ = newTestLimiter(Limits{ConnectionRate: 1, ConnectionBurst: 2, MaxConnections: 1})
+	for i := 0; i < 2; i++ {
+		_, ok = limiter.allowPacket("10.0.0.2")
+		assert.Assert(t, ok)
+	}
+	reason, ok = limiter.allowPacket("10.0.0.2")
+	assert.Assert(t, !ok)
+	assert.Equal(t, reason, RefuseReasonRateLimited)
+	// packets reserve no connection
+	_, ok = limiter.allow("10.0.0.3")
+	assert.Assert(t, ok)
+}
+
+func TestConnLimiter_Prune(t *testing.T) {
+	limiter, clock := newTestLimiter(Limits{})
+	_, ok := limiter.allow("10.0.0.1")
+	assert.Assert(t, ok)
+	_, ok = limiter.allow("10.0.0.2")
+	assert.Assert(t, ok)
+	limiter.release("10.0.0.1")
+
+	clock.now = clock.now.Add(ipStateIdleTimeout + time.Second)
+	_, ok = limiter.allow("10.0.0.3")
+	assert.Assert(t, ok)
+	_, found := limiter.ips["10.0.0.1"]
+	assert.Assert(t, !found)
+	_, found = limiter.ips["10.0.0.2"]
+	assert.Assert(t, found)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// device transports used as metric labels
+const (
+	TransportTCP = "tcp"
+	TransportUDP = "udp"
+)
+
+// handshake results used as metric labels
+const (
+	HandshakeAccepted = "accepted"
+	HandshakeDeclined = "declined"
+)
+
+// database operations used as metric labels
+const (
+	dbOpSavePoints  = "save_points"
+	dbOpSaveRawData = "save_raw_data"
+	dbOpSinkPoints  = "sink_points"
+)
+
+// nats subjects used as metric labels
+const (
+	natsSubjectLastPoint        = "lastpoint"
+	natsSubjectPoints           = "points"
+	natsSubjectDuplicateSession = "duplicate_session"
+)
+
+// prometheus metrics of the gateway, served by promhttp.Handler
+var (
+	activeConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "teltonika",
+		Name:      "active_connections",
+		Help:      "Open tcp device connections.",
+	})
+	handshakesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "handshakes_total",
+		Help:      "IMEI handshakes by result and decline reason.",
+	}, []string{"result", "reason"})
+	packetsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "packets_total",
+		Help:      "Received AVL packets by transport and codec.",
+	}, []string{"transport", "codec"})
+	recordsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "records_total",
+		Help:      "Decoded AVL records by transport and codec.",
+	}, []string{"transport", "codec"})
+	parseErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "parse_errors_total",
+		Help:      "AVL packets failed to parse by error type.",
+	}, []string{"type"})
+	crcFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "crc_failures_total",
+		Help:      "AVL packets with invalid CRC by transport.",
+	}, []string{"transport"})
+	ackLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "teltonika",
+		Name:      "ack_latency_seconds",
+		Help:      "Time from receiving an AVL packet until it is acked.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"transport"})
+	dbInsertLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "teltonika",
+		Name:      "db_insert_duration_seconds",
+		Help:      "Latency of writing to avl database and sinks by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+	dbInsertErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "db_insert_errors_total",
+		Help:      "Failed writes to avl database and sinks by operation.",
+	}, []string{"op"})
+	closedConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "closed_connections_total",
+		Help:      "Closed tcp device connections by close reason.",
+	}, []string{"reason"})
+	refusedConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "refused_connections_total",
+		Help:      "Tcp connections closed on accept by refuse reason.",
+	}, []string{"reason"})
+	rejectedDevicesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "rejected_devices_total",
+		Help:      "Devices declined by the registry or imei validation by reason.",
+	}, []string{"reason"})
+	udpPacketsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "udp_packets_total",
+		Help:      "Received udp packets by result.",
+	}, []string{"result"})
+	natsPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teltonika",
+		Name:      "nats_publish_failures_total",
+		Help:      "Failed nats publishes by subject.",
+	}, []string{"subject"})
+)
+
+// parseErrorType returns metric label of a ParsePacket error
+func parseErrorType(err error) string {
+	switch {
+	case errors.Is(err, parser.ErrTruncatedPacket):
+		return "truncated"
+	case errors.Is(err, parser.ErrUnsupportedCodec):
+		return "unsupported_codec"
+	case errors.Is(err, parser.ErrInvalidHeader):
+		return "invalid_header"
+	case errors.Is(err, parser.ErrInvalidNumberOfData):
+		return "invalid_number_of_data"
+	case errors.Is(err, parser.ErrCheckCRC):
+		return "crc"
+	}
+	return "other"
+}
+
+// observeInsert runs insert in a child span of ctx and records its latency and failure under op
+func observeInsert(ctx context.Context, op string, insert func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, op)
+	start := time.Now()
+	err := insert(ctx)
+	dbInsertLatency.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		dbInsertErrorsTotal.WithLabelValues(op).Inc()
+	}
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseErrorType(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"truncated":         {err: parser.ErrTruncatedPacket, want: "truncated"},
+		"unsupported codec": {err: parser.ErrUnsupportedCodec, want: "unsupported_codec"},
+		"invalid header":    {err: parser.ErrInvalidHeader, want: "invalid_header"},
+		"number of data":    {err: fmt.Errorf("wrapped:%w", parser.ErrInvalidNumberOfData), want: "invalid_number_of_data"},
+		"crc":               {err: parser.ErrCheckCRC, want: "crc"},
+		"other":             {err: fmt.Errorf("parse io elements failed"), want: "other"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, parseErrorType(test.err), test.want)
+		})
+	}
+}
+
+func TestConnectionMetrics(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	ctrl := gomock.NewController(t)
+	dbConn := mockdb.NewMockAVLDBConn(ctrl)
+	dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).Return(nil)
+	dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Len(2)).Return(fmt.Errorf("insert failed"))
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+
+	accepted := testutil.ToFloat64(handshakesTotal.WithLabelValues(HandshakeAccepted, ""))
+	packets := testutil.ToFloat64(packetsTotal.WithLabelValues(TransportTCP, "8E"))
+	records := testutil.ToFloat64(recordsTotal.WithLabelValues(TransportTCP, "8E"))
+	insertErrors := testutil.ToFloat64(dbInsertErrorsTotal.WithLabelValues(dbOpSavePoints))
+
+	server := NewServer("", zap.NewNop(), natsClient, dbConn).(*TeltonikaServer)
+	clientConn, serverConn := net.Pipe()
+	server.wg.Add(1)
+	go server.HandleConnection(serverConn)
+	ImeiAuthenticate(t, clientConn, "356478954125694")
+	assert.Equal(t, testutil.ToFloat64(activeConnections), float64(1))
+	SendPoints(t, clientConn, []*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7},
+		{Priority: parser.PriorityLow, Longitude: 51.5, Latitude: 35.8},
+	})
+	clientConn.Close()
+	server.wg.Wait()
+
+	assert.Equal(t, testutil.ToFloat64(activeConnections), float64(0))
+	assert.Equal(t, testutil.ToFloat64(handshakesTotal.WithLabelValues(HandshakeAccepted, "")), accepted+1)
+	assert.Equal(t, testutil.ToFloat64(packetsTotal.WithLabelValues(TransportTCP, "8E")), packets+1)
+	assert.Equal(t, testutil.ToFloat64(recordsTotal.WithLabelValues(TransportTCP, "8E")), records+2)
+	assert.Equal(t, testutil.ToFloat64(dbInsertErrorsTotal.WithLabelValues(dbOpSavePoints)), insertErrors+1)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	gatewayv1 "github.com/irisco88/teltonika-device/proto/gateway/v1"
+	"github.com/irisco88/teltonika-device/tracing"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrInvalidPublishMode = errors.New("points publish mode must be record, batch or off")
+
+const (
+	// LastPointSubject receives the newest pb.AVLData of every packet, formatted with imei
+	LastPointSubject = "device.lastpoint.%s"
+	// PointsSubject receives every record of a device, formatted with imei
+	PointsSubject = "device.points.%s"
+)
+
+// Content-Type header values of published messages, consumers of PointsSubject use it to decode
+const (
+	ContentTypeAVLData    = "application/x-protobuf; messageType=device.v1.AVLData"
+	ContentTypePointBatch = "application/x-protobuf; messageType=gateway.v1.PointBatch"
+)
+
+const (
+	lastPointPruneInterval = time.Minute
+	// lastPointIdleTimeout forgets devices which published no last point for this long
+	lastPointIdleTimeout = time.Hour
+)
+
+type lastPointState struct {
+	timestamp time.Time
+	updatedAt time.Time
+}
+
+// lastPointTimes keeps timestamp of the newest published last point by imei, idle devices are forgotten
+type lastPointTimes struct {
+	mu        sync.Mutex
+	states    map[string]lastPointState
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func newLastPointTimes() *lastPointTimes {
+	return &lastPointTimes{
+		states: make(map[string]lastPointState),
+		now:    time.Now,
+	}
+}
+
+// advance records timestamp as the newest of imei, it returns false when a newer timestamp was recorded
+func (lt *lastPointTimes) advance(imei string, timestamp time.Time) bool {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	now := lt.now()
+	lt.prune(now)
+	if state, found := lt.states[imei]; found && timestamp.Before(state.timestamp) {
+		return false
+	}
+	lt.states[imei] = lastPointState{timestamp: timestamp, updatedAt: now}
+	return true
+}
+
+func (lt *lastPointTimes) prune(now time.Time) {
+	if now.Sub(lt.lastPrune) < lastPointPruneInterval {
+		return
+	}
+	lt.lastPrune = now
+	for imei, state := range lt.states {
+		if now.Sub(state.updatedAt) > lastPointIdleTimeout {
+			delete(lt.states, imei)
+		}
+	}
+}
+
+// PublishMode decides how records are published on PointsSubject
+type PublishMode string
+
+const (
+	// PublishRecord publishes every record as a pb.AVLData message
+	PublishRecord PublishMode = "record"
+	// PublishBatch publishes records of a packet as one gatewayv1.PointBatch message
+	PublishBatch PublishMode = "batch"
+	// PublishOff publishes only the last point
+	PublishOff PublishMode = "off"
+)
+
+func ParsePublishMode(mode string) (PublishMode, error) {
+	switch PublishMode(mode) {
+	case PublishRecord, PublishBatch, PublishOff:
+		return PublishMode(mode), nil
+	}
+	return "", ErrInvalidPublishMode
+}
+
+// WithPublishMode sets how records are published on PointsSubject, every record is published by default
+func WithPublishMode(mode PublishMode) Option {
+	return func(ts *TeltonikaServer) {
+		ts.publishMode = mode
+	}
+}
+
+// PublishPoints publishes records of a packet on PointsSubject according to publish mode
+func (ts *TeltonikaServer) PublishPoints(ctx context.Context, imei string, points []*pb.AVLData) {
+	subject := fmt.Sprintf(PointsSubject, imei)
+	switch ts.publishMode {
+	case PublishRecord:
+		for _, point := range points {
+			if err := ts.publish(ctx, subject, natsSubjectPoints, ContentTypeAVLData, point); err != nil {
+				ts.log.Error("publish point failed", zap.Error(err), zap.String("imei", imei))
+			}
+		}
+	case PublishBatch:
+		batch := &gatewayv1.PointBatch{Points: points}
+		if err := ts.publish(ctx, subject, natsSubjectPoints, ContentTypePointBatch, batch); err != nil {
+			ts.log.Error("publish points failed", zap.Error(err), zap.String("imei", imei))
+		}
+	}
+}
+
+// publish sends message to nats with trace context of ctx in message headers,
+// failures are counted with metricSubject label
+func (ts *TeltonikaServer) publish(ctx context.Context, subject, metricSubject, contentType string, message proto.Message) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("marshal failed:%w", err)
+	}
+	ctx, span := tracer.Start(ctx, "nats.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", subject)),
+	)
+	msg := &nats.Msg{Subject: subject, Data: data, Header: nats.Header{}}
+	msg.Header.Set("Content-Type", contentType)
+	tracing.InjectNATS(ctx, msg)
+	err = ts.natsConn.PublishMsg(msg)
+	endSpan(span, err)
+	if err != nil {
+		natsPublishFailuresTotal.WithLabelValues(metricSubject).Inc()
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	gatewayv1 "github.com/irisco88/teltonika-device/proto/gateway/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"gotest.tools/v3/assert"
+)
+
+func TestParsePublishMode(t *testing.T) {
+	for _, mode := range []string{"record", "batch", "off"} {
+		parsed, err := ParsePublishMode(mode)
+		assert.NilError(t, err)
+		assert.Equal(t, string(parsed), mode)
+	}
+	_, err := ParsePublishMode("all")
+	assert.ErrorIs(t, err, ErrInvalidPublishMode)
+}
+
+func TestPublishPoints(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	const imei = "356478954125694"
+	// stored records are uploaded after the current one when coverage returns
+	points := []*pb.AVLData{
+		{Imei: imei, Timestamp: "2023-09-27 09:00:00"},
+		{Imei: imei, Timestamp: "2023-09-27 07:33:20"},
+		{Imei: imei, Timestamp: "2023-09-27 07:33:30"},
+	}
+	tests := map[string]struct {
+		mode      PublishMode
+		wantCount int
+		wantType  string
+	}{
+		"record": {mode: PublishRecord, wantCount: 3, wantType: ContentTypeAVLData},
+		"batch":  {mode: PublishBatch, wantCount: 1, wantType: ContentTypePointBatch},
+		"off":    {mode: PublishOff, wantCount: 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			pointMsgs, err := natsClient.SubscribeSync(fmt.Sprintf(PointsSubject, imei))
+			assert.NilError(t, err)
+			lastPoints, err := natsClient.SubscribeSync(fmt.Sprintf(LastPointSubject, imei))
+			assert.NilError(t, err)
+			assert.NilError(t, natsClient.Flush())
+
+			server := NewServer("", zap.NewNop(), natsClient, nil, WithPublishMode(test.mode)).(*TeltonikaServer)
+			server.PublishPoints(context.Background(), imei, points)
+			server.PublishLastPoint(context.Background(), imei, points)
+			assert.NilError(t, natsClient.Flush())
+
+			var received []*pb.AVLData
+			for i := 0; i < test.wantCount; i++ {
+				msg, err := pointMsgs.NextMsg(time.Second)
+				assert.NilError(t, err)
+				assert.Equal(t, msg.Header.Get("Content-Type"), test.wantType)
+				if test.mode == PublishBatch {
+					batch := &gatewayv1.PointBatch{}
+					assert.NilError(t, proto.Unmarshal(msg.Data, batch))
+					received = append(received, batch.GetPoints()...)
+					continue
+				}
+				point := &pb.AVLData{}
+				assert.NilError(t, proto.Unmarshal(msg.Data, point))
+				received = append(received, point)
+			}
+			if test.mode != PublishOff {
+				assert.Equal(t, len(received), len(points))
+				for i, point := range points {
+					assert.Equal(t, received[i].GetTimestamp(), point.GetTimestamp())
+				}
+			}
+			_, err = pointMsgs.NextMsg(time.Millisecond * 50)
+			assert.Assert(t, err != nil)
+
+			// last point is the newest record, not the last one of the packet
+			msg, err := lastPoints.NextMsg(time.Second)
+			assert.NilError(t, err)
+			lastPoint := &pb.AVLData{}
+			assert.NilError(t, proto.Unmarshal(msg.Data, lastPoint))
+			assert.Equal(t, lastPoint.GetTimestamp(), "2023-09-27 09:00:00")
+		})
+	}
+}
+
+func TestPublishLastPoint_SkipsOlder(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+	const imei = "356478954125694"
+	lastPoints, err := natsClient.SubscribeSync(fmt.Sprintf(LastPointSubject, imei))
+	assert.NilError(t, err)
+	assert.NilError(t, natsClient.Flush())
+
+	server := NewServer("", zap.NewNop(), natsClient, nil, WithPublishMode(PublishOff)).(*TeltonikaServer)
+	packets := [][]*pb.AVLData{
+		{{Imei: imei, Timestamp: "2023-09-27 09:00:00"}},
+		// stored records uploaded in a later packet after coverage returns
+		{{Imei: imei, Timestamp: "2023-09-27 07:33:20"}, {Imei: imei, Timestamp: "2023-09-27 07:33:30"}},
+		{{Imei: imei, Timestamp: "2023-09-27 09:00:00"}},
+		{{Imei: imei, Timestamp: "2023-09-27 09:00:10"}},
+	}
+	for _, points := range packets {
+		server.PublishLastPoint(context.Background(), imei, points)
+	}
+	assert.NilError(t, natsClient.Flush())
+
+	var published []string
+	for {
+		msg, err := lastPoints.NextMsg(time.Millisecond * 100)
+		if err != nil {
+			break
+		}
+		point := &pb.AVLData{}
+		assert.NilError(t, proto.Unmarshal(msg.Data, point))
+		published = append(published, point.GetTimestamp())
+	}
+	assert.DeepEqual(t, published, []string{"2023-09-27 09:00:00", "2023-09-27 09:00:00", "2023-09-27 09:00:10"})
+}
+
+func TestLastPointTimes_Prune(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 9, 27, 9, 0, 0, 0, time.UTC)}
+	times := newLastPointTimes()
+	times.now = clock.Now
+	newest := time.Date(2023, 9, 27, 9, 0, 0, 0, time.UTC)
+	assert.Assert(t, times.advance("356478954125694", newest))
+	assert.Assert(t, times.advance("356307042441013", newest))
+	assert.Assert(t, !times.advance("356478954125694", newest.Add(-time.Minute)))
+
+	clock.now = clock.now.Add(lastPointIdleTimeout / 2)
+	assert.Assert(t, times.advance("356307042441013", newest.Add(time.Minute)))
+	clock.now = clock.now.Add(lastPointIdleTimeout/2 + time.Second)
+	// idle devices are forgotten, so their older points are published again
+	assert.Assert(t, times.advance("356478954125694", newest.Add(-time.Minute)))
+	assert.Equal(t, len(times.states), 2)
+	assert.Assert(t, !times.advance("356307042441013", newest))
+}
@@ -2,81 +2,310 @@ package server
 
 import (
 	"context"
-	pb "github.com/irisco88/protos/gen/device/v1"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 
-	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
-	avldb "github.com/irisco88/teltonika-device/db/clickhouse"
+	"github.com/irisco88/teltonika-device/command"
+	"github.com/irisco88/teltonika-device/db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/proxyproto"
+	"github.com/irisco88/teltonika-device/registry"
+	"github.com/irisco88/teltonika-device/session"
 
 	"go.uber.org/zap"
 )
 
-type AVLDBConn interface {
-	GetConn() driver.Conn
-	SaveAvlPoints(ctx context.Context, points []*pb.AVLData) error
-	SaveRawData(ctx context.Context, imei, payload string) error
-}
 type Empty struct{}
 
+var (
+	ErrNoListener   = errors.New("no tcp, tls or udp listen address is configured")
+	ErrNotListening = errors.New("server is not listening")
+	ErrShuttingDown = errors.New("server is shutting down")
+)
+
 type TeltonikaServer struct {
 	listenAddr string
 	ln         net.Listener
+	tlsConfig  *TLSConfig
+	tlsLn      net.Listener
 	quitChan   chan Empty
 	wg         sync.WaitGroup
 	log        *zap.Logger
 	natsConn   *nats.Conn
-	avlDB      avldb.AVLDBConn
+	avlDB      db.AVLDBConn
+	sinks      []db.PointSink
+	auth       registry.Authenticator
+	imeiMode   parser.IMEIMode
+	sessions   *session.Registry
+	commands   *command.Log
+	// duplicatePolicy applies when a device connects while it already has a session
+	duplicatePolicy session.DuplicatePolicy
+	timeouts        Timeouts
+	limits          Limits
+	limiter         *connLimiter
+	// trustedProxies are sources whose connections start with a PROXY protocol header
+	trustedProxies []*net.IPNet
+	udpAddr        string
+	udpConn        net.PacketConn
+	// conns are device connections closed or interrupted on shutdown
+	connsMu   sync.Mutex
+	conns     map[net.Conn]Empty
+	draining  bool
+	listening bool
+	stopOnce  sync.Once
+	// publishMode decides how records are published besides the last point
+	publishMode PublishMode
+	// queryFirmware queues getver for devices whose firmware is not known yet
+	queryFirmware bool
+	// firmware keeps firmware versions by imei from getver responses, so later sessions know it
+	firmware sync.Map
+	// lastPoints keeps timestamp of the newest published last point by imei
+	lastPoints *lastPointTimes
+}
+
+// Timeouts of device connections, zero disables a timeout
+type Timeouts struct {
+	// Handshake limits time from accept until IMEI is received
+	Handshake time.Duration
+	// Idle limits time between data packets
+	Idle time.Duration
+	// Write limits time of writing an ack to device
+	Write time.Duration
+	// KeepAlive is TCP keepalive period, zero uses system default and negative disables keepalive
+	KeepAlive time.Duration
+}
+
+// DefaultTimeouts are used unless WithTimeouts is given
+var DefaultTimeouts = Timeouts{
+	Handshake: time.Second * 30,
+	Idle:      time.Minute * 10,
+	Write:     time.Second * 10,
+	KeepAlive: time.Minute,
+}
+
+// Option configures optional server behaviour
+type Option func(ts *TeltonikaServer)
+
+// WithPointSink adds a sink which receives decoded points besides avl database
+func WithPointSink(sink db.PointSink) Option {
+	return func(ts *TeltonikaServer) {
+		ts.sinks = append(ts.sinks, sink)
+	}
 }
 
 const PRECISION = 10000000.0
 
 type TcpServerInterface interface {
-	Start()
+	Start() error
 	Stop()
 	AcceptConnections()
 	HandleConnection(conn net.Conn)
+	Shutdown(ctx context.Context) error
+	Ready() error
+	Sessions() *session.Registry
+	Commands() *command.Log
 }
 
 var (
 	_ TcpServerInterface = &TeltonikaServer{}
 )
 
+// WithAuthenticator declines devices rejected by auth, all devices are accepted without it
+func WithAuthenticator(auth registry.Authenticator) Option {
+	return func(ts *TeltonikaServer) {
+		ts.auth = auth
+	}
+}
+
+// WithIMEIMode sets IMEI validation of handshake, IMEIs are validated strictly by default
+func WithIMEIMode(mode parser.IMEIMode) Option {
+	return func(ts *TeltonikaServer) {
+		ts.imeiMode = mode
+	}
+}
+
+// WithDuplicatePolicy sets duplicate session policy, old sessions are closed by default
+func WithDuplicatePolicy(policy session.DuplicatePolicy) Option {
+	return func(ts *TeltonikaServer) {
+		ts.duplicatePolicy = policy
+	}
+}
+
+// WithFirmwareQuery sends getver to devices whose firmware version is not known yet,
+// without it versions are only learned from getver commands queued on admin api
+func WithFirmwareQuery() Option {
+	return func(ts *TeltonikaServer) {
+		ts.queryFirmware = true
+	}
+}
+
+// WithTimeouts sets connection timeouts and keepalive
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(ts *TeltonikaServer) {
+		ts.timeouts = timeouts
+	}
+}
+
+// WithLimits sets connection limits, rate limiting and handshake failure bans
+func WithLimits(limits Limits) Option {
+	return func(ts *TeltonikaServer) {
+		ts.limits = limits
+	}
+}
+
+// WithTLS adds a TLS listener, plaintext listener is disabled when listenAddr of server is empty
+func WithTLS(cfg *TLSConfig) Option {
+	return func(ts *TeltonikaServer) {
+		ts.tlsConfig = cfg
+	}
+}
+
+// WithProxyProtocol reads PROXY protocol v1/v2 header of connections from trusted networks,
+// so logs, sessions, limits and raw frames carry the device address instead of the balancer
+func WithProxyProtocol(trusted []*net.IPNet) Option {
+	return func(ts *TeltonikaServer) {
+		ts.trustedProxies = trusted
+	}
+}
+
 func NewServer(listenAddr string,
 	logger *zap.Logger,
 	natsConn *nats.Conn,
-	avlDB avldb.AVLDBConn) TcpServerInterface {
-	return &TeltonikaServer{
+	avlDB db.AVLDBConn,
+	opts ...Option) TcpServerInterface {
+	ts := &TeltonikaServer{
 		listenAddr: listenAddr,
 		quitChan:   make(chan Empty),
 		wg:         sync.WaitGroup{},
 		log:        logger,
 		natsConn:   natsConn,
 		avlDB:      avlDB,
+		sessions:   session.NewRegistry(),
+		commands:   command.NewLog(command.DefaultLogSize),
+		conns:      make(map[net.Conn]Empty),
+
+		duplicatePolicy: session.DuplicateCloseOld,
+		timeouts:        DefaultTimeouts,
+		limits:          DefaultLimits,
+		publishMode:     PublishRecord,
+		lastPoints:      newLastPointTimes(),
+	}
+	for _, opt := range opts {
+		opt(ts)
 	}
+	ts.limiter = newConnLimiter(ts.limits)
+	return ts
 }
 
-func (ts *TeltonikaServer) Start() {
-	ln, err := net.Listen("tcp", ts.listenAddr)
+// Start binds listeners and serves devices until server is stopped,
+// it returns an error without serving when a listener can not be bound
+func (ts *TeltonikaServer) Start() error {
+	tlsConfig, err := ts.listen()
 	if err != nil {
-		ts.log.Error("failed to listen", zap.Error(err))
-		return
+		ts.closeListeners()
+		return err
+	}
+	ts.connsMu.Lock()
+	ts.listening = true
+	ts.connsMu.Unlock()
+	if ts.ln != nil {
+		go ts.AcceptConnections()
+		ts.log.Info("server started",
+			zap.String("ListenAddress", ts.listenAddr),
+		)
+	}
+	if ts.tlsLn != nil {
+		go ts.acceptConnections(ts.tlsLn, tlsConfig)
+		ts.log.Info("tls server started",
+			zap.String("ListenAddress", ts.tlsConfig.ListenAddr),
+		)
+	}
+	if ts.udpConn != nil {
+		go ts.serveUDP(ts.udpConn)
+		ts.log.Info("udp server started",
+			zap.String("ListenAddress", ts.udpAddr),
+		)
 	}
-	defer ln.Close()
-	ts.ln = ln
-
-	go ts.AcceptConnections()
-	ts.log.Info("server started",
-		zap.String("ListenAddress", ts.listenAddr),
-	)
 	<-ts.quitChan
+	return nil
+}
+
+// listen binds configured listeners and returns tls config of tls listener
+func (ts *TeltonikaServer) listen() (*tls.Config, error) {
+	if ts.listenAddr == "" && ts.tlsConfig == nil && ts.udpAddr == "" {
+		return nil, ErrNoListener
+	}
+	listenConfig := net.ListenConfig{KeepAlive: ts.timeouts.KeepAlive}
+	if ts.listenAddr != "" {
+		ln, err := listenConfig.Listen(context.Background(), "tcp", ts.listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen:%w", err)
+		}
+		ts.ln = ln
+	}
+	var tlsConfig *tls.Config
+	if ts.tlsConfig != nil {
+		var err error
+		tlsConfig, err = newTLSConfig(ts.tlsConfig, ts.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls config:%w", err)
+		}
+		ln, err := listenConfig.Listen(context.Background(), "tcp", ts.tlsConfig.ListenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen tls:%w", err)
+		}
+		// tls handshake runs per connection, after PROXY protocol header is read
+		ts.tlsLn = ln
+	}
+	if ts.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", ts.udpAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen udp:%w", err)
+		}
+		ts.udpConn = conn
+	}
+	return tlsConfig, nil
+}
+
+func (ts *TeltonikaServer) closeListeners() {
+	if ts.ln != nil {
+		ts.ln.Close()
+	}
+	if ts.tlsLn != nil {
+		ts.tlsLn.Close()
+	}
+	if ts.udpConn != nil {
+		ts.udpConn.Close()
+	}
+}
+
+// Ready reports whether server listens for devices and is not shutting down
+func (ts *TeltonikaServer) Ready() error {
+	ts.connsMu.Lock()
+	defer ts.connsMu.Unlock()
+	switch {
+	case ts.draining:
+		return ErrShuttingDown
+	case !ts.listening:
+		return ErrNotListening
+	}
+	return nil
 }
 
 func (ts *TeltonikaServer) AcceptConnections() {
+	ts.acceptConnections(ts.ln, nil)
+}
+
+// acceptConnections accepts connections of ln, connections are wrapped in TLS when tlsConfig is not nil
+func (ts *TeltonikaServer) acceptConnections(ln net.Listener, tlsConfig *tls.Config) {
 	for {
-		conn, err := ts.ln.Accept()
+		conn, err := ln.Accept()
 		if err != nil {
 			// Check if the error is due to the listener being closed
 			if opErr, ok := err.(*net.OpError); ok && opErr.Err.Error() == "use of closed network connection" {
@@ -85,18 +314,64 @@ func (ts *TeltonikaServer) AcceptConnections() {
 			ts.log.Error("failed to accept connection", zap.Error(err))
 			continue
 		}
-		ts.log.Info("new Connection to the server", zap.String("Address", conn.RemoteAddr().String()))
-		ts.wg.Add(1)
-		go ts.HandleConnection(conn)
+		if !ts.beginWork() {
+			conn.Close()
+			continue
+		}
+		go ts.serveConnection(conn, tlsConfig)
 	}
 }
 
+// serveConnection reads PROXY protocol header, applies connection limits and handles the connection
+func (ts *TeltonikaServer) serveConnection(conn net.Conn, tlsConfig *tls.Config) {
+	if len(ts.trustedProxies) > 0 && proxyproto.Trusted(conn.RemoteAddr(), ts.trustedProxies) {
+		ts.setReadDeadline(conn, ts.timeouts.Handshake)
+		proxyConn, _, err := proxyproto.Accept(conn)
+		if err != nil {
+			ts.log.Warn("read proxy protocol header failed",
+				zap.String("proxy", conn.RemoteAddr().String()),
+				zap.Error(err),
+			)
+			conn.Close()
+			ts.wg.Done()
+			return
+		}
+		conn = proxyConn
+	}
+	ip := remoteIP(conn)
+	if reason, ok := ts.limiter.allow(ip); !ok {
+		refusedConnectionsTotal.WithLabelValues(reason).Inc()
+		ts.log.Warn("connection refused",
+			zap.String("Address", conn.RemoteAddr().String()),
+			zap.String("reason", reason),
+		)
+		conn.Close()
+		ts.wg.Done()
+		return
+	}
+	defer ts.limiter.release(ip)
+	if tlsConfig != nil {
+		conn = tls.Server(conn, tlsConfig)
+	}
+	ts.log.Info("new Connection to the server", zap.String("Address", conn.RemoteAddr().String()))
+	ts.HandleConnection(conn)
+}
+
+// Stop shuts server down gracefully within DefaultShutdownTimeout
 func (ts *TeltonikaServer) Stop() {
-	ts.log.Info("stopping server")
-	// Close the listener to stop accepting new connections
-	if ts.ln != nil {
-		ts.ln.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	if err := ts.Shutdown(ctx); err != nil {
+		ts.log.Error("shutdown failed", zap.Error(err))
 	}
-	close(ts.quitChan)
-	ts.wg.Wait()
+}
+
+// Sessions returns registry of connected devices
+func (ts *TeltonikaServer) Sessions() *session.Registry {
+	return ts.sessions
+}
+
+// Commands returns log of GPRS commands queued for devices
+func (ts *TeltonikaServer) Commands() *command.Log {
+	return ts.commands
 }
@@ -2,16 +2,24 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/golang/mock/gomock"
-	mockdb "github.com/irisco88/teltonika-device/db/clickhouse/mock_db"
+	"github.com/irisco88/teltonika-device/db"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
 	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/registry"
+	"github.com/irisco88/teltonika-device/session"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 	"google.golang.org/protobuf/testing/protocmp"
 	"gotest.tools/v3/assert"
+	"io"
 	"net"
 	"testing"
+	"time"
 )
 
 func TestSendData(t *testing.T) {
@@ -27,7 +35,7 @@ func TestSendData(t *testing.T) {
 		errWant    error
 	}{
 		"success": {
-			imei: "356478954125698",
+			imei: "356478954125694",
 			points: []*parser.AVLData{
 				{
 					//Timestamp:  nowTime,
@@ -46,6 +54,16 @@ func TestSendData(t *testing.T) {
 					//},
 				},
 			},
+			MockDB: func(ctx context.Context, dbConn *mockdb.MockAVLDBConn) {
+				dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, frame *db.RawFrame) error {
+						if frame.IMEI != "356478954125694" || frame.ParseError != "" || !frame.CRCValid || frame.RecordCount != 1 {
+							return fmt.Errorf("unexpected raw frame: %+v", frame)
+						}
+						return nil
+					})
+				dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Len(1)).Return(nil)
+			},
 		},
 	}
 	for name, test := range tests {
@@ -54,18 +72,25 @@ func TestSendData(t *testing.T) {
 
 			ctrl := gomock.NewController(t)
 			dbConn := mockdb.NewMockAVLDBConn(ctrl)
+			if test.MockDB != nil {
+				test.MockDB(context.Background(), dbConn)
+			}
 
-			observerlog, out := observer.New(zap.InfoLevel)
+			observerlog, out := observer.New(zap.ErrorLevel)
 			logger := zap.New(observerlog)
 
 			natsClient := NewNatsConnection(t, natsServer.ClientURL())
 			server := NewServer(serverConn.LocalAddr().String(), logger, natsClient, dbConn).(*TeltonikaServer)
-			go func() {
-				server.wg.Add(1)
-				server.HandleConnection(serverConn)
-			}()
+			server.wg.Add(1)
+			go server.HandleConnection(serverConn)
 			ImeiAuthenticate(t, clientConn, test.imei)
 			SendPoints(t, clientConn, test.points)
+			devSess, found := server.Sessions().Get(test.imei)
+			assert.Assert(t, found)
+			assert.Equal(t, devSess.Info().RecordsReceived, uint64(len(test.points)))
+			clientConn.Close()
+			server.wg.Wait()
+			assert.Equal(t, server.Sessions().Len(), 0)
 			logs := out.TakeAll()
 			assert.Assert(t, len(logs) == len(test.logEntry))
 			for i, log := range logs {
@@ -76,3 +101,230 @@ func TestSendData(t *testing.T) {
 		})
 	}
 }
+
+func TestDeclineRejectedDevice(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	auth := registry.NewAuthenticator(registry.NewMemoryStore(
+		&registry.Device{IMEI: "356478954125694"},
+		&registry.Device{IMEI: "352094087982671", Status: registry.StatusBlocked},
+	))
+	// unknown device, blocked device and invalid luhn check digit
+	for _, imei := range []string{"356307042441013", "352094087982671", "356478954125697"} {
+		t.Run(imei, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			ctrl := gomock.NewController(t)
+			dbConn := mockdb.NewMockAVLDBConn(ctrl)
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			server := NewServer(serverConn.LocalAddr().String(), zap.NewNop(), natsClient, dbConn,
+				WithAuthenticator(auth),
+			).(*TeltonikaServer)
+			server.wg.Add(1)
+			go server.HandleConnection(serverConn)
+
+			_, err := clientConn.Write(append([]byte{0, 15}, imei...))
+			assert.NilError(t, err)
+			buf := make([]byte, 1)
+			_, err = io.ReadFull(clientConn, buf)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, buf, []byte{0})
+			_, err = clientConn.Read(buf)
+			assert.ErrorIs(t, err, io.EOF)
+			server.wg.Wait()
+		})
+	}
+}
+
+// authFunc is an Authenticator backed by a function
+type authFunc func(ctx context.Context, imei string) error
+
+func (f authFunc) Authenticate(ctx context.Context, imei string) error {
+	return f(ctx, imei)
+}
+
+func TestRegistryErrorDoesNotBan(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	var hasDeadline bool
+	auth := authFunc(func(ctx context.Context, _ string) error {
+		_, hasDeadline = ctx.Deadline()
+		return errors.New("registry unavailable")
+	})
+	clientConn, serverConn := net.Pipe()
+	ctrl := gomock.NewController(t)
+	dbConn := mockdb.NewMockAVLDBConn(ctrl)
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	server := NewServer(serverConn.LocalAddr().String(), zap.NewNop(), natsClient, dbConn,
+		WithAuthenticator(auth),
+		WithLimits(Limits{MaxHandshakeFailures: 1, BanDuration: time.Minute}),
+	).(*TeltonikaServer)
+	server.wg.Add(1)
+	go server.HandleConnection(serverConn)
+
+	_, err := clientConn.Write(append([]byte{0, 15}, "356478954125694"...))
+	assert.NilError(t, err)
+	buf := make([]byte, 1)
+	_, err = io.ReadFull(clientConn, buf)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, buf, []byte{0})
+	server.wg.Wait()
+	assert.Assert(t, hasDeadline)
+
+	_, allowed := server.limiter.allow(remoteIP(serverConn))
+	assert.Assert(t, allowed)
+}
+
+func TestDuplicateSession(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	const imei = "356478954125694"
+	tests := map[string]struct {
+		policy     session.DuplicatePolicy
+		wantAction string
+	}{
+		"close old":  {policy: session.DuplicateCloseOld, wantAction: DuplicateActionClosedOld},
+		"reject new": {policy: session.DuplicateRejectNew, wantAction: DuplicateActionRejectedNew},
+		"allow":      {policy: session.DuplicateAllow, wantAction: DuplicateActionAllowed},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			events, err := natsClient.SubscribeSync(fmt.Sprintf(DuplicateSessionSubject, imei))
+			assert.NilError(t, err)
+			assert.NilError(t, natsClient.Flush())
+			server := NewServer("", zap.NewNop(), natsClient, nil,
+				WithDuplicatePolicy(test.policy),
+			).(*TeltonikaServer)
+
+			oldClient, oldServer := net.Pipe()
+			server.wg.Add(1)
+			go server.HandleConnection(oldServer)
+			ImeiAuthenticate(t, oldClient, imei)
+
+			newClient, newServer := net.Pipe()
+			server.wg.Add(1)
+			go server.HandleConnection(newServer)
+			imeiBytes, err := parser.EncodeIMEIToHex(imei)
+			assert.NilError(t, err)
+			_, err = newClient.Write(imeiBytes)
+			assert.NilError(t, err)
+			buf := make([]byte, 1)
+			_, err = io.ReadFull(newClient, buf)
+			assert.NilError(t, err)
+
+			msg, err := events.NextMsg(time.Second)
+			assert.NilError(t, err)
+			event := &DuplicateSessionEvent{}
+			assert.NilError(t, json.Unmarshal(msg.Data, event))
+			assert.Equal(t, event.Action, test.wantAction)
+			assert.Equal(t, event.Policy, test.policy)
+
+			registered, found := server.Sessions().Get(imei)
+			assert.Assert(t, found)
+			switch test.policy {
+			case session.DuplicateCloseOld:
+				assert.DeepEqual(t, buf, []byte{1})
+				_, err = oldClient.Read(buf)
+				assert.ErrorIs(t, err, io.EOF)
+				assert.Equal(t, registered.Conn(), newServer)
+			case session.DuplicateRejectNew:
+				assert.DeepEqual(t, buf, []byte{0})
+				assert.Equal(t, registered.Conn(), oldServer)
+			case session.DuplicateAllow:
+				assert.DeepEqual(t, buf, []byte{1})
+				assert.Equal(t, registered.Conn(), newServer)
+			}
+			oldClient.Close()
+			newClient.Close()
+			server.wg.Wait()
+		})
+	}
+}
+
+func TestConnectionTimeouts(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	tests := map[string]struct {
+		authenticate bool
+		wantReason   string
+	}{
+		"handshake timeout": {wantReason: CloseReasonHandshakeTimeout},
+		"idle timeout":      {authenticate: true, wantReason: CloseReasonIdleTimeout},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			observerlog, out := observer.New(zap.InfoLevel)
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			server := NewServer("", zap.New(observerlog), natsClient, nil,
+				WithTimeouts(Timeouts{
+					Handshake: time.Millisecond * 50,
+					Idle:      time.Millisecond * 50,
+					Write:     time.Second,
+				}),
+			).(*TeltonikaServer)
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+			server.wg.Add(1)
+			go server.HandleConnection(serverConn)
+			if test.authenticate {
+				ImeiAuthenticate(t, clientConn, "356478954125694")
+			}
+			_, err := clientConn.Read(make([]byte, 1))
+			assert.ErrorIs(t, err, io.EOF)
+			server.wg.Wait()
+
+			closedLogs := out.FilterMessage("connection closed").AllUntimed()
+			assert.Equal(t, len(closedLogs), 1)
+			assert.Equal(t, closedLogs[0].ContextMap()["reason"], test.wantReason)
+		})
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	const imei = "356478954125694"
+	tests := map[string]struct {
+		trusted    string
+		wantAccept bool
+		wantAddr   string
+	}{
+		"trusted balancer":   {trusted: "127.0.0.0/8", wantAccept: true, wantAddr: "10.1.2.3:45678"},
+		"untrusted balancer": {trusted: "10.0.0.0/8"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			_, trusted, err := net.ParseCIDR(test.trusted)
+			assert.NilError(t, err)
+			server := NewServer("", zap.NewNop(), natsClient, nil,
+				WithProxyProtocol([]*net.IPNet{trusted}),
+			).(*TeltonikaServer)
+			server.ln, err = net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			go server.AcceptConnections()
+			defer server.Stop()
+
+			client, err := net.Dial("tcp", server.ln.Addr().String())
+			assert.NilError(t, err)
+			defer client.Close()
+			_, err = client.Write([]byte("PROXY TCP4 10.1.2.3 10.0.0.1 45678 5000\r\n"))
+			assert.NilError(t, err)
+			if !test.wantAccept {
+				// header is read as imei from untrusted sources
+				buf := make([]byte, 1)
+				_, err = io.ReadFull(client, buf)
+				assert.NilError(t, err)
+				assert.DeepEqual(t, buf, []byte{0})
+				return
+			}
+			ImeiAuthenticate(t, client, imei)
+			devSess, found := server.Sessions().Get(imei)
+			assert.Assert(t, found)
+			assert.Equal(t, devSess.Info().RemoteAddr, test.wantAddr)
+		})
+	}
+}
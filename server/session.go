@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/irisco88/teltonika-device/session"
+	"go.uber.org/zap"
+)
+
+// DuplicateSessionSubject receives DuplicateSessionEvent, formatted with imei
+const DuplicateSessionSubject = "device.session.duplicate.%s"
+
+const (
+	DuplicateActionClosedOld   = "closed_old"
+	DuplicateActionRejectedNew = "rejected_new"
+	DuplicateActionAllowed     = "allowed"
+)
+
+// DuplicateSessionEvent is published when a device connects while it already has a session
+type DuplicateSessionEvent struct {
+	IMEI           string                  `json:"imei"`
+	Policy         session.DuplicatePolicy `json:"policy"`
+	Action         string                  `json:"action"`
+	OldRemoteAddr  string                  `json:"old_remote_addr"`
+	OldConnectedAt time.Time               `json:"old_connected_at"`
+	NewRemoteAddr  string                  `json:"new_remote_addr"`
+	Time           time.Time               `json:"time"`
+}
+
+// registerSession registers session of a new connection applying duplicate policy,
+// false means the new connection must be declined
+func (ts *TeltonikaServer) registerSession(devSess *session.Session) bool {
+	previous, accepted := ts.sessions.Register(devSess, ts.duplicatePolicy)
+	if previous == nil {
+		return true
+	}
+	previousInfo := previous.Info()
+	event := &DuplicateSessionEvent{
+		IMEI:           devSess.IMEI(),
+		Policy:         ts.duplicatePolicy,
+		OldRemoteAddr:  previousInfo.RemoteAddr,
+		OldConnectedAt: previousInfo.ConnectedAt,
+		NewRemoteAddr:  devSess.Info().RemoteAddr,
+		Time:           time.Now(),
+	}
+	switch {
+	case !accepted:
+		event.Action = DuplicateActionRejectedNew
+	case ts.duplicatePolicy == session.DuplicateAllow:
+		event.Action = DuplicateActionAllowed
+	default:
+		event.Action = DuplicateActionClosedOld
+		if err := previous.Close(); err != nil {
+			ts.log.Error("close old session failed", zap.Error(err), zap.String("imei", event.IMEI))
+		}
+	}
+	ts.log.Warn("duplicate session",
+		zap.String("imei", event.IMEI),
+		zap.String("action", event.Action),
+		zap.String("oldRemoteAddr", event.OldRemoteAddr),
+		zap.String("newRemoteAddr", event.NewRemoteAddr),
+	)
+	ts.publishDuplicateSession(event)
+	return accepted
+}
+
+func (ts *TeltonikaServer) publishDuplicateSession(event *DuplicateSessionEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		ts.log.Error("marshal duplicate session event failed", zap.Error(err))
+		return
+	}
+	if e := ts.natsConn.Publish(fmt.Sprintf(DuplicateSessionSubject, event.IMEI), data); e != nil {
+		natsPublishFailuresTotal.WithLabelValues(natsSubjectDuplicateSession).Inc()
+		ts.log.Error("publish duplicate session event failed", zap.Error(e))
+	}
+}
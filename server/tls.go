@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidClientCA = errors.New("client ca file contains no certificates")
+	ErrMissingClientCA = errors.New("client ca file is required to require client certificates")
+)
+
+// TLSConfig configures the optional TLS listener, it runs next to the plaintext listener
+type TLSConfig struct {
+	ListenAddr string
+	CertFile   string
+	KeyFile    string
+	// ClientCAFile verifies client certificates signed by these CAs when set
+	ClientCAFile string
+	// RequireClientCert declines devices without a client certificate signed by ClientCAFile
+	RequireClientCert bool
+}
+
+// certCheckInterval limits how often handshakes check cert and key files for changes
+const certCheckInterval = time.Second * 10
+
+// certReloader loads certificate again when cert or key file changes
+type certReloader struct {
+	certFile      string
+	keyFile       string
+	checkInterval time.Duration
+	log           *zap.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger *zap.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: certCheckInterval,
+		log:           logger,
+	}
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if e := cr.load(modTime); e != nil {
+		return nil, e
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate failed:%v", err)
+	}
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.checkedAt = time.Now()
+	return nil
+}
+
+// GetCertificate returns current certificate, files are checked at most once per check interval
+// and previous certificate is kept when reload fails
+func (cr *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if time.Since(cr.checkedAt) < cr.checkInterval {
+		return cr.cert, nil
+	}
+	cr.checkedAt = time.Now()
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		cr.log.Error("stat tls certificate failed", zap.Error(err))
+		return cr.cert, nil
+	}
+	if modTime.Equal(cr.modTime) {
+		return cr.cert, nil
+	}
+	if e := cr.load(modTime); e != nil {
+		cr.log.Error("reload tls certificate failed", zap.Error(e))
+		return cr.cert, nil
+	}
+	cr.log.Info("tls certificate reloaded", zap.String("certFile", cr.certFile))
+	return cr.cert, nil
+}
+
+// newTLSConfig creates server tls config of cfg
+func newTLSConfig(cfg *TLSConfig, logger *zap.Logger) (*tls.Config, error) {
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		// without client cas certificates would be verified against system roots
+		return nil, ErrMissingClientCA
+	}
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.ClientCAFile != "" {
+		caData, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, ErrInvalidClientCA
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if cfg.RequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate signed by parent, a self signed CA when parent is nil
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NilError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (tc *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(tc.certPEM, tc.keyPEM)
+	assert.NilError(t, err)
+	return cert
+}
+
+func writeTestCert(t *testing.T, dir string, cert *testCert, modTime time.Time) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NilError(t, os.WriteFile(certFile, cert.certPEM, 0o600))
+	assert.NilError(t, os.WriteFile(keyFile, cert.keyPEM, 0o600))
+	assert.NilError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NilError(t, os.Chtimes(keyFile, modTime, modTime))
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	first := newTestCert(t, "first.example.com", ca)
+	certFile, keyFile := writeTestCert(t, dir, first, time.Now().Add(-time.Minute))
+	reloader, err := newCertReloader(certFile, keyFile, zap.NewNop())
+	assert.NilError(t, err)
+	cert, err := reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cert.Certificate[0], first.cert.Raw)
+
+	// files are not checked again within check interval
+	second := newTestCert(t, "second.example.com", ca)
+	writeTestCert(t, dir, second, time.Now())
+	cert, err = reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cert.Certificate[0], first.cert.Raw)
+
+	reloader.checkInterval = 0
+	cert, err = reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cert.Certificate[0], second.cert.Raw)
+
+	// broken files keep the loaded certificate
+	assert.NilError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	cert, err = reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cert.Certificate[0], second.cert.Raw)
+}
+
+func TestNewTLSConfig_RequireClientCertWithoutCA(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), newTestCert(t, "teltonika.example.com", nil), time.Now())
+	_, err := newTLSConfig(&TLSConfig{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		RequireClientCert: true,
+	}, zap.NewNop())
+	assert.ErrorIs(t, err, ErrMissingClientCA)
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile := writeTestCert(t, dir, newTestCert(t, "teltonika.example.com", ca), time.Now())
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NilError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	tlsConfig, err := newTLSConfig(&TLSConfig{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		ClientCAFile:      caFile,
+		RequireClientCert: true,
+	}, zap.NewNop())
+	assert.NilError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := map[string]struct {
+		clientCerts []tls.Certificate
+		wantAccept  bool
+	}{
+		"client certificate":         {clientCerts: []tls.Certificate{newTestCert(t, "356478954125694", ca).tlsCertificate(t)}, wantAccept: true},
+		"missing client certificate": {},
+		"untrusted client certificate": {
+			clientCerts: []tls.Certificate{newTestCert(t, "356478954125694", newTestCert(t, "other ca", nil)).tlsCertificate(t)},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			server := NewServer("", zap.NewNop(), natsClient, nil).(*TeltonikaServer)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			server.tlsLn = ln
+			go server.acceptConnections(server.tlsLn, tlsConfig)
+			defer server.Stop()
+
+			client, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+				ServerName:   "teltonika.example.com",
+				RootCAs:      roots,
+				Certificates: test.clientCerts,
+				MinVersion:   tls.VersionTLS12,
+			})
+			assert.NilError(t, err)
+			defer client.Close()
+			if test.wantAccept {
+				ImeiAuthenticate(t, client, "356478954125694")
+			} else {
+				// tls 1.3 client reports rejected certificate on first read
+				_, err := client.Write([]byte{0, 15})
+				if err == nil {
+					_, err = client.Read(make([]byte, 1))
+				}
+				assert.Assert(t, err != nil)
+			}
+		})
+	}
+}
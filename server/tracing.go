@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates spans of received frames, it follows the global tracer provider
+var tracer = otel.Tracer("github.com/irisco88/teltonika-device/server")
+
+// span attributes of frames
+const (
+	attrIMEI        = attribute.Key("teltonika.imei")
+	attrTransport   = attribute.Key("teltonika.transport")
+	attrCodec       = attribute.Key("teltonika.codec")
+	attrRecordCount = attribute.Key("teltonika.record_count")
+)
+
+// startFrameSpan starts root span of a received frame
+func startFrameSpan(transport, imei string) (context.Context, trace.Span) {
+	return tracer.Start(context.Background(), "teltonika.frame",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrIMEI.String(imei), attrTransport.String(transport)),
+	)
+}
+
+// endSpan records err on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
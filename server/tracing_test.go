@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func TestFrameSpans(t *testing.T) {
+	const imei = "356478954125694"
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+	otel.SetTracerProvider(provider)
+	tracing.SetPropagator()
+
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	natsClient := NewNatsConnection(t, natsServer.ClientURL())
+	defer natsClient.Close()
+	lastPoints, err := natsClient.SubscribeSync(fmt.Sprintf("device.lastpoint.%s", imei))
+	assert.NilError(t, err)
+	assert.NilError(t, natsClient.Flush())
+
+	ctrl := gomock.NewController(t)
+	dbConn := mockdb.NewMockAVLDBConn(ctrl)
+	dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).Return(nil)
+	dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Len(2)).Return(nil)
+	server := NewServer("", zap.NewNop(), natsClient, dbConn).(*TeltonikaServer)
+	clientConn, serverConn := net.Pipe()
+	server.wg.Add(1)
+	go server.HandleConnection(serverConn)
+	ImeiAuthenticate(t, clientConn, imei)
+	SendPoints(t, clientConn, []*parser.AVLData{
+		{Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7},
+		{Priority: parser.PriorityLow, Longitude: 51.5, Latitude: 35.8},
+	})
+	msg, err := lastPoints.NextMsg(time.Second)
+	assert.NilError(t, err)
+	clientConn.Close()
+	server.wg.Wait()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	frame, found := spans["teltonika.frame"]
+	assert.Assert(t, found)
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range frame.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	assert.Equal(t, attrs[attrIMEI].AsString(), imei)
+	assert.Equal(t, attrs[attrCodec].AsString(), "8E")
+	assert.Equal(t, attrs[attrRecordCount].AsInt64(), int64(2))
+	for _, name := range []string{"parse", dbOpSavePoints, dbOpSaveRawData, "nats.publish"} {
+		span, found := spans[name]
+		assert.Assert(t, found, name)
+		assert.Equal(t, span.Parent().SpanID(), frame.SpanContext().SpanID(), name)
+	}
+
+	publishSpan := spans["nats.publish"].SpanContext()
+	remote := tracing.ExtractNATS(context.Background(), msg)
+	assert.Equal(t, trace.SpanContextFromContext(remote).SpanID(), publishSpan.SpanID())
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/registry"
+	"go.uber.org/zap"
+)
+
+// udp packet results recorded in metrics, packets refused by limits are recorded with their refuse reason
+const (
+	UDPResultAccepted   = "accepted"
+	UDPResultInvalid    = "invalid"
+	UDPResultRejected   = "rejected"
+	UDPResultParseError = "parse_error"
+	UDPResultDropped    = "dropped"
+)
+
+// WithUDP adds a UDP listener for devices using teltonika UDP channel
+func WithUDP(listenAddr string) Option {
+	return func(ts *TeltonikaServer) {
+		ts.udpAddr = listenAddr
+	}
+}
+
+// serveUDP reads datagrams of conn until it is closed, every datagram is handled in its own goroutine.
+// Datagrams of banned or rate limited ips are dropped before decoding, and datagrams are dropped
+// while MaxUDPPackets datagrams are being handled
+func (ts *TeltonikaServer) serveUDP(conn net.PacketConn) {
+	var slots chan Empty
+	if ts.limits.MaxUDPPackets > 0 {
+		slots = make(chan Empty, ts.limits.MaxUDPPackets)
+	}
+	buf := make([]byte, parser.MaxUDPPacketLength)
+	for {
+		size, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ts.isDraining() {
+				return
+			}
+			ts.log.Error("failed to read udp packet", zap.Error(err))
+			continue
+		}
+		if reason, ok := ts.limiter.allowPacket(addrIP(addr)); !ok {
+			udpPacketsTotal.WithLabelValues(reason).Inc()
+			continue
+		}
+		if slots != nil {
+			select {
+			case slots <- Empty{}:
+			default:
+				udpPacketsTotal.WithLabelValues(UDPResultDropped).Inc()
+				continue
+			}
+		}
+		if !ts.beginWork() {
+			if slots != nil {
+				<-slots
+			}
+			continue
+		}
+		data := make([]byte, size)
+		copy(data, buf[:size])
+		go func() {
+			defer ts.wg.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			ts.HandleUDPPacket(conn, addr, data)
+		}()
+	}
+}
+
+// HandleUDPPacket decodes a UDP channel packet, delivers its points and acks accepted records.
+// Invalid, rejected and unparsable packets are not acked, devices resend them
+func (ts *TeltonikaServer) HandleUDPPacket(conn net.PacketConn, addr net.Addr, data []byte) {
+	receivedAt := time.Now()
+	packet, err := parser.DecodeUDPPacket(data, ts.imeiMode)
+	if err != nil {
+		udpPacketsTotal.WithLabelValues(UDPResultInvalid).Inc()
+		ts.log.Error("decode udp packet failed",
+			zap.Error(err),
+			zap.String("ip", addr.String()),
+		)
+		ts.udpHandshakeFailed(addr)
+		return
+	}
+	if ts.auth != nil {
+		ctx, cancel := ts.handshakeContext()
+		e := ts.auth.Authenticate(ctx, packet.IMEI)
+		cancel()
+		if e != nil {
+			rejectReason := registry.Reason(e)
+			udpPacketsTotal.WithLabelValues(UDPResultRejected).Inc()
+			rejectedDevicesTotal.WithLabelValues(rejectReason).Inc()
+			ts.log.Warn("device rejected",
+				zap.String("imei", packet.IMEI),
+				zap.String("reason", rejectReason),
+				zap.String("ip", addr.String()),
+				zap.Error(e),
+			)
+			// registry errors are not counted, an unavailable registry must not ban every device
+			if registry.Rejected(e) {
+				ts.udpHandshakeFailed(addr)
+			}
+			return
+		}
+	}
+	ts.limiter.handshakeSucceeded(addrIP(addr))
+	ctx, span := startFrameSpan(TransportUDP, packet.IMEI)
+	points, err := ts.processFrame(ctx, TransportUDP, packet.IMEI, addr.String(), packet.Frame)
+	if err != nil {
+		endSpan(span, err)
+		udpPacketsTotal.WithLabelValues(UDPResultParseError).Inc()
+		return
+	}
+	udpPacketsTotal.WithLabelValues(UDPResultAccepted).Inc()
+	_, e := conn.WriteTo(parser.EncodeUDPAck(packet.PacketID, packet.AVLPacketID, uint8(len(points))), addr)
+	endSpan(span, e)
+	if e != nil {
+		ts.log.Error("response udp ack failed",
+			zap.Error(e),
+			zap.String("imei", packet.IMEI),
+		)
+		return
+	}
+	ackLatency.WithLabelValues(TransportUDP).Observe(time.Since(receivedAt).Seconds())
+}
+
+// udpHandshakeFailed counts an invalid or rejected packet of addr like a failed handshake
+func (ts *TeltonikaServer) udpHandshakeFailed(addr net.Addr) {
+	if ts.limiter.handshakeFailed(addrIP(addr)) {
+		ts.log.Warn("ip banned after failed handshakes",
+			zap.String("ip", addrIP(addr)),
+			zap.Duration("duration", ts.limits.BanDuration),
+		)
+	}
+}
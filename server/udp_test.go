@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/irisco88/teltonika-device/db"
+	mockdb "github.com/irisco88/teltonika-device/db/mock_db"
+	"github.com/irisco88/teltonika-device/parser"
+	"github.com/irisco88/teltonika-device/registry"
+	"go.uber.org/zap"
+	"gotest.tools/v3/assert"
+)
+
+func TestUDPPacket(t *testing.T) {
+	natsServer := RunNatsServerOnPort(0)
+	defer natsServer.Shutdown()
+	auth := registry.NewAuthenticator(registry.NewMemoryStore(
+		&registry.Device{IMEI: "356478954125694"},
+	))
+	points := []*parser.AVLData{
+		{Timestamps: 1700000000000, Priority: parser.PriorityHigh, Longitude: 51.4, Latitude: 35.7, Speed: 40},
+		{Timestamps: 1700000001000, Priority: parser.PriorityLow, Longitude: 51.5, Latitude: 35.8, Speed: 45},
+	}
+	tests := map[string]struct {
+		imei    string
+		banned  bool
+		MockDB  func(dbConn *mockdb.MockAVLDBConn)
+		wantAck []byte
+	}{
+		"accepted": {
+			imei: "356478954125694",
+			MockDB: func(dbConn *mockdb.MockAVLDBConn) {
+				dbConn.EXPECT().SaveRawData(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, frame *db.RawFrame) error {
+						if frame.IMEI != "356478954125694" || !frame.CRCValid || frame.RecordCount != 2 {
+							return fmt.Errorf("unexpected raw frame: %+v", frame)
+						}
+						return nil
+					})
+				dbConn.EXPECT().SaveAvlPoints(gomock.Any(), gomock.Len(2)).Return(nil)
+			},
+			wantAck: []byte{0, 5, 0xca, 0xfe, 1, 7, 2},
+		},
+		"rejected device": {
+			imei: "356307042441013",
+		},
+		"banned ip": {
+			imei:   "356478954125694",
+			banned: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			dbConn := mockdb.NewMockAVLDBConn(ctrl)
+			if test.MockDB != nil {
+				test.MockDB(dbConn)
+			}
+			natsClient := NewNatsConnection(t, natsServer.ClientURL())
+			defer natsClient.Close()
+			lastPoints, err := natsClient.SubscribeSync(fmt.Sprintf("device.lastpoint.%s", test.imei))
+			assert.NilError(t, err)
+			assert.NilError(t, natsClient.Flush())
+
+			server := NewServer("", zap.NewNop(), natsClient, dbConn,
+				WithAuthenticator(auth),
+				WithLimits(Limits{MaxHandshakeFailures: 1, BanDuration: time.Minute, MaxUDPPackets: 8}),
+			).(*TeltonikaServer)
+			if test.banned {
+				assert.Assert(t, server.limiter.handshakeFailed("127.0.0.1"))
+			}
+			server.udpConn, err = net.ListenPacket("udp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			go server.serveUDP(server.udpConn)
+			defer server.Stop()
+
+			client, err := net.Dial("udp", server.udpConn.LocalAddr().String())
+			assert.NilError(t, err)
+			defer client.Close()
+			packet, err := parser.MakeUDPPacket(0xcafe, 7, test.imei, points)
+			assert.NilError(t, err)
+			_, err = client.Write(packet)
+			assert.NilError(t, err)
+
+			assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Millisecond*300)))
+			buf := make([]byte, 64)
+			size, err := client.Read(buf)
+			if test.wantAck == nil {
+				var netErr net.Error
+				assert.Assert(t, err != nil && errors.As(err, &netErr) && netErr.Timeout())
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, buf[:size], test.wantAck)
+			_, err = lastPoints.NextMsg(time.Second)
+			assert.NilError(t, err)
+		})
+	}
+}
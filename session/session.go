@@ -0,0 +1,190 @@
+package session
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+)
+
+var ErrInvalidDuplicatePolicy = errors.New("duplicate policy must be close-old, reject-new or allow")
+
+// DuplicatePolicy decides what happens when a device connects while it already has a session
+type DuplicatePolicy string
+
+const (
+	// DuplicateCloseOld closes previous session, it is usually a half-open connection left after reconnect
+	DuplicateCloseOld DuplicatePolicy = "close-old"
+	// DuplicateRejectNew declines new connection and keeps previous session
+	DuplicateRejectNew DuplicatePolicy = "reject-new"
+	// DuplicateAllow keeps both connections, the new session becomes authoritative
+	DuplicateAllow DuplicatePolicy = "allow"
+)
+
+func ParseDuplicatePolicy(policy string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(policy) {
+	case DuplicateCloseOld, DuplicateRejectNew, DuplicateAllow:
+		return DuplicatePolicy(policy), nil
+	}
+	return "", ErrInvalidDuplicatePolicy
+}
+
+// Info is a snapshot of a connected device session
+type Info struct {
+	IMEI            string    `json:"imei"`
+	RemoteAddr      string    `json:"remote_addr"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	LastPacketAt    time.Time `json:"last_packet_at,omitempty"`
+	BytesReceived   uint64    `json:"bytes_received"`
+	FramesReceived  uint64    `json:"frames_received"`
+	RecordsReceived uint64    `json:"records_received"`
+	CodecID         uint8     `json:"codec_id,omitempty"`
+	Firmware        string    `json:"firmware,omitempty"`
+}
+
+// lastSessionID numbers sessions, so sessions of the same imei can be told apart
+var lastSessionID atomic.Uint64
+
+// Session is a connection of an authenticated device
+type Session struct {
+	id   uint64
+	mu   sync.Mutex
+	info Info
+	conn net.Conn
+	// lastPacket are records of the last decoded data packet
+	lastPacket []*pb.AVLData
+}
+
+func New(imei string, conn net.Conn) *Session {
+	return &Session{
+		id: lastSessionID.Add(1),
+		info: Info{
+			IMEI:        imei,
+			RemoteAddr:  conn.RemoteAddr().String(),
+			ConnectedAt: time.Now(),
+		},
+		conn: conn,
+	}
+}
+
+// ID is unique among sessions of the process
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+func (s *Session) IMEI() string {
+	return s.info.IMEI
+}
+
+// Conn returns connection of session, writes must not interleave with data acks
+func (s *Session) Conn() net.Conn {
+	return s.conn
+}
+
+// RecordFrame updates counters after a data frame of size bytes with records is received
+func (s *Session) RecordFrame(size, records int, codecID uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.LastPacketAt = time.Now()
+	s.info.BytesReceived += uint64(size)
+	s.info.FramesReceived++
+	s.info.RecordsReceived += uint64(records)
+	s.info.CodecID = codecID
+}
+
+// SetLastPacket keeps records of the last decoded data packet
+func (s *Session) SetLastPacket(points []*pb.AVLData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPacket = points
+}
+
+// LastPacket returns records of the last decoded data packet, nil before the first packet
+func (s *Session) LastPacket() []*pb.AVLData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastPacket
+}
+
+// Close closes connection of session, its connection handler stops on next read
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// SetFirmware sets firmware version reported by the device to getver
+func (s *Session) SetFirmware(firmware string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.Firmware = firmware
+}
+
+func (s *Session) Info() Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info
+}
+
+// Registry keeps sessions of connected devices by imei
+type Registry struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Register adds session according to policy and returns previous session of the same imei if there is one.
+// With DuplicateRejectNew session is not added when a previous session exists and accepted is false.
+func (r *Registry) Register(session *Session, policy DuplicatePolicy) (previous *Session, accepted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous = r.sessions[session.IMEI()]
+	if previous != nil && policy == DuplicateRejectNew {
+		return previous, false
+	}
+	r.sessions[session.IMEI()] = session
+	return previous, true
+}
+
+// Remove unregisters session if it is still the registered session of its imei
+func (r *Registry) Remove(session *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions[session.IMEI()] == session {
+		delete(r.sessions, session.IMEI())
+	}
+}
+
+func (r *Registry) Get(imei string) (*Session, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	session, found := r.sessions[imei]
+	return session, found
+}
+
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.sessions)
+}
+
+// List returns snapshots of all sessions sorted by imei
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	infos := make([]Info, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		infos = append(infos, session.Info())
+	}
+	r.mu.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].IMEI < infos[j].IMEI
+	})
+	return infos
+}
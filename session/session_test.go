@@ -0,0 +1,90 @@
+package session
+
+import (
+	"net"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func newTestSession(t *testing.T, imei string) *Session {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	return New(imei, serverConn)
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	first := newTestSession(t, "356307042441013")
+	previous, _ := registry.Register(first, DuplicateCloseOld)
+	assert.Assert(t, previous == nil)
+	previous, _ = registry.Register(newTestSession(t, "352094087982671"), DuplicateCloseOld)
+	assert.Assert(t, previous == nil)
+
+	first.RecordFrame(100, 2, 0x8e)
+	first.RecordFrame(50, 1, 0x8e)
+	info := first.Info()
+	assert.Equal(t, info.BytesReceived, uint64(150))
+	assert.Equal(t, info.FramesReceived, uint64(2))
+	assert.Equal(t, info.RecordsReceived, uint64(3))
+	assert.Equal(t, info.CodecID, uint8(0x8e))
+	assert.Assert(t, !info.LastPacketAt.IsZero())
+
+	// reconnect replaces session, removing the old one must keep the new one
+	second := newTestSession(t, "356307042441013")
+	assert.Assert(t, second.ID() != first.ID())
+	previous, _ = registry.Register(second, DuplicateCloseOld)
+	assert.Assert(t, previous == first)
+	registry.Remove(first)
+	session, found := registry.Get("356307042441013")
+	assert.Assert(t, found)
+	assert.Assert(t, session == second)
+
+	infos := registry.List()
+	assert.Equal(t, len(infos), 2)
+	assert.Equal(t, infos[0].IMEI, "352094087982671")
+	registry.Remove(second)
+	assert.Equal(t, registry.Len(), 1)
+}
+
+func TestRegistry_Register(t *testing.T) {
+	tests := map[string]struct {
+		policy       DuplicatePolicy
+		wantAccepted bool
+	}{
+		"close old":  {policy: DuplicateCloseOld, wantAccepted: true},
+		"reject new": {policy: DuplicateRejectNew, wantAccepted: false},
+		"allow":      {policy: DuplicateAllow, wantAccepted: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry()
+			first := newTestSession(t, "356307042441013")
+			previous, accepted := registry.Register(first, test.policy)
+			assert.Assert(t, previous == nil)
+			assert.Assert(t, accepted)
+
+			second := newTestSession(t, "356307042441013")
+			previous, accepted = registry.Register(second, test.policy)
+			assert.Assert(t, previous == first)
+			assert.Equal(t, accepted, test.wantAccepted)
+			registered, _ := registry.Get("356307042441013")
+			if test.wantAccepted {
+				assert.Assert(t, registered == second)
+			} else {
+				assert.Assert(t, registered == first)
+			}
+		})
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	policy, err := ParseDuplicatePolicy("reject-new")
+	assert.NilError(t, err)
+	assert.Equal(t, policy, DuplicateRejectNew)
+	_, err = ParseDuplicatePolicy("close-new")
+	assert.ErrorIs(t, err, ErrInvalidDuplicatePolicy)
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+var (
+	ErrInvalidSampleRatio = errors.New("trace sample ratio must be between 0 and 1")
+)
+
+// DefaultServiceName is reported as service.name of spans
+const DefaultServiceName = "teltonika-device"
+
+type Options struct {
+	// Endpoint is the OTLP gRPC collector address, e.g. localhost:4317
+	Endpoint string
+	// Insecure disables TLS to collector
+	Insecure    bool
+	ServiceName string
+	// SampleRatio of traces started by the gateway, parent sampling decisions are respected
+	SampleRatio float64
+}
+
+func (o *Options) Validate() error {
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return ErrInvalidSampleRatio
+	}
+	return nil
+}
+
+// Setup installs an OTLP exporting tracer provider and W3C trace context propagator globally,
+// returned shutdown flushes pending spans
+func Setup(ctx context.Context, opts Options) (func(ctx context.Context) error, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	SetPropagator()
+	return provider.Shutdown, nil
+}
+
+// SetPropagator installs W3C trace context and baggage propagator globally
+func SetPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// HeaderCarrier carries trace context in nats message headers, keys are used as is
+type HeaderCarrier nats.Header
+
+var _ propagation.TextMapCarrier = HeaderCarrier{}
+
+func (c HeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// InjectNATS writes trace context of ctx to headers of msg
+func InjectNATS(ctx context.Context, msg *nats.Msg) {
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(msg.Header))
+}
+
+// ExtractNATS returns ctx continuing trace context of msg headers
+func ExtractNATS(ctx context.Context, msg *nats.Msg) context.Context {
+	if msg.Header == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(msg.Header))
+}
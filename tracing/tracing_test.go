@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/v3/assert"
+)
+
+func TestNATSPropagation(t *testing.T) {
+	SetPropagator()
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+	ctx, span := provider.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	msg := &nats.Msg{Subject: "device.lastpoint.356307042441013"}
+	InjectNATS(ctx, msg)
+	assert.Assert(t, msg.Header.Get("traceparent") != "")
+
+	extracted := trace.SpanContextFromContext(ExtractNATS(context.Background(), msg))
+	assert.Equal(t, extracted.TraceID(), span.SpanContext().TraceID())
+	assert.Equal(t, extracted.SpanID(), span.SpanContext().SpanID())
+	assert.Assert(t, extracted.IsRemote())
+
+	empty := trace.SpanContextFromContext(ExtractNATS(context.Background(), &nats.Msg{}))
+	assert.Assert(t, !empty.IsValid())
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := map[string]struct {
+		opts    Options
+		errWant error
+	}{
+		"valid":          {opts: Options{SampleRatio: 0.5}},
+		"negative ratio": {opts: Options{SampleRatio: -0.1}, errWant: ErrInvalidSampleRatio},
+		"ratio above 1":  {opts: Options{SampleRatio: 1.5}, errWant: ErrInvalidSampleRatio},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.opts.Validate()
+			if test.errWant != nil {
+				assert.ErrorIs(t, err, test.errWant)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
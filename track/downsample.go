@@ -0,0 +1,77 @@
+package track
+
+import (
+	"math"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+)
+
+const (
+	metersPerDegreeLat = 110540.0
+	metersPerDegreeLon = 111320.0
+)
+
+// EveryNth keeps every nth point, first and last points are always kept
+func EveryNth(points []*pb.AVLData, n int) []*pb.AVLData {
+	if n <= 1 || len(points) <= 2 {
+		return points
+	}
+	result := make([]*pb.AVLData, 0, len(points)/n+2)
+	for i := 0; i < len(points)-1; i += n {
+		result = append(result, points[i])
+	}
+	return append(result, points[len(points)-1])
+}
+
+// Simplify reduces points with Douglas-Peucker algorithm, tolerance is in meters
+func Simplify(points []*pb.AVLData, tolerance float64) []*pb.AVLData {
+	if tolerance <= 0 || len(points) <= 2 {
+		return points
+	}
+	// project to local plane around first point so tolerance can be compared in meters
+	cosLat := math.Cos(points[0].GetGps().GetLatitude() * math.Pi / 180)
+	xy := make([][2]float64, len(points))
+	for i, point := range points {
+		xy[i] = [2]float64{
+			point.GetGps().GetLongitude() * metersPerDegreeLon * cosLat,
+			point.GetGps().GetLatitude() * metersPerDegreeLat,
+		}
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	// iterative stack avoids deep recursion on long tracks
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		segment := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		start, end := segment[0], segment[1]
+		maxDistance, maxIndex := 0.0, -1
+		for i := start + 1; i < end; i++ {
+			if distance := segmentDistance(xy[i], xy[start], xy[end]); distance > maxDistance {
+				maxDistance, maxIndex = distance, i
+			}
+		}
+		if maxIndex >= 0 && maxDistance > tolerance {
+			keep[maxIndex] = true
+			stack = append(stack, [2]int{start, maxIndex}, [2]int{maxIndex, end})
+		}
+	}
+	result := make([]*pb.AVLData, 0)
+	for i, point := range points {
+		if keep[i] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// segmentDistance returns distance of p from segment a-b
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
@@ -0,0 +1,63 @@
+package track
+
+import (
+	"testing"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+)
+
+func makePoints(coordinates ...[2]float64) []*pb.AVLData {
+	points := make([]*pb.AVLData, 0, len(coordinates))
+	for _, coordinate := range coordinates {
+		points = append(points, &pb.AVLData{
+			Gps: &pb.GPS{Latitude: coordinate[0], Longitude: coordinate[1]},
+		})
+	}
+	return points
+}
+
+func TestEveryNth(t *testing.T) {
+	points := makePoints([2]float64{1, 1}, [2]float64{2, 2}, [2]float64{3, 3}, [2]float64{4, 4}, [2]float64{5, 5})
+	tests := map[string]struct {
+		n    int
+		want []*pb.AVLData
+	}{
+		"disabled":    {n: 0, want: points},
+		"every one":   {n: 1, want: points},
+		"every two":   {n: 2, want: []*pb.AVLData{points[0], points[2], points[4]}},
+		"every three": {n: 3, want: []*pb.AVLData{points[0], points[3], points[4]}},
+		"larger":      {n: 10, want: []*pb.AVLData{points[0], points[4]}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.DeepEqual(t, EveryNth(points, test.n), test.want, protocmp.Transform())
+		})
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// a straight line along a meridian with a spike of ~550 meters in the middle
+	points := makePoints(
+		[2]float64{35.700, 51.400},
+		[2]float64{35.701, 51.400},
+		[2]float64{35.702, 51.406},
+		[2]float64{35.703, 51.400},
+		[2]float64{35.704, 51.400},
+	)
+	tests := map[string]struct {
+		tolerance float64
+		want      []*pb.AVLData
+	}{
+		"disabled":        {tolerance: 0, want: points},
+		"keeps spike":     {tolerance: 200, want: []*pb.AVLData{points[0], points[2], points[4]}},
+		"removes spike":   {tolerance: 1000, want: []*pb.AVLData{points[0], points[4]}},
+		"keeps all above": {tolerance: 0.1, want: points},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.DeepEqual(t, Simplify(points, test.tolerance), test.want, protocmp.Transform())
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package track
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Path is where track api is served
+const Path = "/api/v1/track"
+
+var (
+	ErrMissingToken = errors.New("track api token is required")
+	errUnauthorized = errors.New("unauthorized")
+)
+
+// Handler serves GET /api/v1/track?imei=&from=&to=&every=&tolerance=&fields=&format=,
+// every request needs the bearer token
+type Handler struct {
+	service *Service
+	token   []byte
+}
+
+// NewHandler creates http api of track service
+func NewHandler(service *Service, token string) (*Handler, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	return &Handler{
+		service: service,
+		token:   []byte(token),
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="track"`)
+		writeHTTPError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	h.service.serveHTTP(w, r)
+}
+
+func (s *Service) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	req, err := parseHTTPRequest(r)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	points, err := s.Query(r.Context(), req)
+	if err != nil {
+		if isRequestError(err) {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log.Error("track query failed", zap.Error(err), zap.String("imei", req.IMEI))
+		writeHTTPError(w, http.StatusInternalServerError, errors.New("track query failed"))
+		return
+	}
+	data, contentType, err := Encode(points, req.Format)
+	if err != nil {
+		s.log.Error("encode track failed", zap.Error(err))
+		writeHTTPError(w, http.StatusInternalServerError, errors.New("encode track failed"))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+func parseHTTPRequest(r *http.Request) (*Request, error) {
+	query := r.URL.Query()
+	req := &Request{
+		IMEI:   query.Get("imei"),
+		Format: query.Get("format"),
+	}
+	var err error
+	if req.From, err = time.Parse(time.RFC3339, query.Get("from")); err != nil {
+		return nil, errors.New("from must be RFC3339 time")
+	}
+	if req.To, err = time.Parse(time.RFC3339, query.Get("to")); err != nil {
+		return nil, errors.New("to must be RFC3339 time")
+	}
+	if every := query.Get("every"); every != "" {
+		if req.Every, err = strconv.Atoi(every); err != nil {
+			return nil, errors.New("every must be a number")
+		}
+	}
+	if tolerance := query.Get("tolerance"); tolerance != "" {
+		if req.Tolerance, err = strconv.ParseFloat(tolerance, 64); err != nil {
+			return nil, errors.New("tolerance must be a number")
+		}
+	}
+	if fields := query.Get("fields"); fields != "" {
+		req.Fields = strings.Split(fields, ",")
+	}
+	return req, nil
+}
+
+func isRequestError(err error) bool {
+	return errors.Is(err, ErrMissingIMEI) ||
+		errors.Is(err, ErrInvalidRange) ||
+		errors.Is(err, ErrInvalidSampling) ||
+		errors.Is(err, ErrInvalidFormat) ||
+		errors.Is(err, ErrTooManyPoints)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,135 @@
+package track
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	trackingpb "github.com/irisco88/protos/gen/tracking/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+)
+
+type fakeStore struct {
+	points []*pb.AVLData
+	err    error
+}
+
+func (fs *fakeStore) LoadTrack(_ context.Context, _ string, _, _ time.Time, _ int) ([]*pb.AVLData, error) {
+	return fs.points, fs.err
+}
+
+func TestService_ServeHTTP(t *testing.T) {
+	storedPoints := func() []*pb.AVLData {
+		return []*pb.AVLData{
+			{
+				Imei:      "356307042441013",
+				Timestamp: "2023-06-01 10:00:00",
+				Gps:       &pb.GPS{Latitude: 35.7, Longitude: 51.4},
+				IoElements: []*pb.IOElement{
+					{ElementName: "Ignition", ElementValue: 1},
+					{ElementName: "EngineSpeed_RPM", ElementValue: 1850},
+				},
+			},
+		}
+	}
+	tests := map[string]struct {
+		query       string
+		token       string
+		truncated   bool
+		storeErr    error
+		wantStatus  int
+		wantType    string
+		wantElement []string
+	}{
+		"json": {
+			query:       "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json",
+			wantElement: []string{"Ignition", "EngineSpeed_RPM"},
+		},
+		"protobuf with fields": {
+			query:       "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z&format=protobuf&fields=Ignition",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/x-protobuf",
+			wantElement: []string{"Ignition"},
+		},
+		"missing token": {
+			query:      "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			token:      "-",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"wrong token": {
+			query:      "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			token:      "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"too many points": {
+			query:      "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			truncated:  true,
+			wantStatus: http.StatusBadRequest,
+		},
+		"missing imei": {
+			query:      "from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		"invalid range": {
+			query:      "imei=356307042441013&from=2023-06-02T00:00:00Z&to=2023-06-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		"invalid time": {
+			query:      "imei=356307042441013&from=yesterday&to=2023-06-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		"store failure": {
+			query:      "imei=356307042441013&from=2023-06-01T00:00:00Z&to=2023-06-02T00:00:00Z",
+			storeErr:   errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			service := NewService(&fakeStore{points: storedPoints(), err: test.storeErr}, zap.NewNop())
+			if test.truncated {
+				service.maxPoints = len(storedPoints()) - 1
+			}
+			handler, err := NewHandler(service, "secret")
+			assert.NilError(t, err)
+			req := httptest.NewRequest(http.MethodGet, Path+"?"+test.query, nil)
+			switch test.token {
+			case "":
+				req.Header.Set("Authorization", "Bearer secret")
+			case "-":
+			default:
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			assert.Equal(t, recorder.Code, test.wantStatus)
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, recorder.Header().Get("Content-Type"), test.wantType)
+			response := &trackingpb.AllPointsDataResponse{}
+			if test.wantType == "application/x-protobuf" {
+				assert.NilError(t, proto.Unmarshal(recorder.Body.Bytes(), response))
+			} else {
+				assert.NilError(t, protojson.Unmarshal(recorder.Body.Bytes(), response))
+			}
+			assert.Equal(t, len(response.GetPoints()), 1)
+			var names []string
+			for _, element := range response.GetPoints()[0].GetIoElements() {
+				names = append(names, element.GetElementName())
+			}
+			assert.DeepEqual(t, names, test.wantElement)
+			assert.DeepEqual(t, response.GetPoints()[0].GetGps(), storedPoints()[0].GetGps(), protocmp.Transform())
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package track
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	// QuerySubject receives json encoded Request, reply is encoded in requested format
+	QuerySubject = "device.track.query"
+	queueGroup   = "teltonika-track"
+	// ErrorHeader is set on replies of failed queries
+	ErrorHeader = "Error"
+)
+
+// SubscribeNats answers track queries sent with nats request/reply
+func (s *Service) SubscribeNats(natsConn *nats.Conn) (*nats.Subscription, error) {
+	return natsConn.QueueSubscribe(QuerySubject, queueGroup, s.handleNatsQuery)
+}
+
+func (s *Service) handleNatsQuery(msg *nats.Msg) {
+	req := &Request{}
+	if err := json.Unmarshal(msg.Data, req); err != nil {
+		s.respondNatsError(msg, errors.New("invalid track request"))
+		return
+	}
+	points, err := s.Query(context.Background(), req)
+	if err != nil {
+		if !isRequestError(err) {
+			s.log.Error("track query failed", zap.Error(err), zap.String("imei", req.IMEI))
+			err = errors.New("track query failed")
+		}
+		s.respondNatsError(msg, err)
+		return
+	}
+	data, _, err := Encode(points, req.Format)
+	if err != nil {
+		s.log.Error("encode track failed", zap.Error(err))
+		s.respondNatsError(msg, errors.New("encode track failed"))
+		return
+	}
+	if e := msg.Respond(data); e != nil {
+		s.log.Error("respond track query failed", zap.Error(e))
+	}
+}
+
+func (s *Service) respondNatsError(msg *nats.Msg, err error) {
+	reply := nats.NewMsg(msg.Reply)
+	reply.Header.Set(ErrorHeader, err.Error())
+	if e := msg.RespondMsg(reply); e != nil {
+		s.log.Error("respond track query failed", zap.Error(e))
+	}
+}
@@ -0,0 +1,133 @@
+package track
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	trackingpb "github.com/irisco88/protos/gen/tracking/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrMissingIMEI     = errors.New("imei is required")
+	ErrInvalidRange    = errors.New("to must be after from")
+	ErrInvalidSampling = errors.New("every and tolerance must not be negative")
+	ErrInvalidFormat   = errors.New("format must be json or protobuf")
+	ErrTooManyPoints   = errors.New("track has too many points, narrow the time range")
+)
+
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+
+	// DefaultMaxPoints limits points loaded from database for a single query,
+	// larger tracks are refused instead of returned partially
+	DefaultMaxPoints = 100000
+	// DefaultQueryTimeout limits time spent on a single query
+	DefaultQueryTimeout = time.Second * 30
+)
+
+// Store loads stored points of a device
+type Store interface {
+	LoadTrack(ctx context.Context, imei string, from, to time.Time, limit int) ([]*pb.AVLData, error)
+}
+
+// Request asks for track of a device in [From, To)
+type Request struct {
+	IMEI string    `json:"imei"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Every keeps every nth point
+	Every int `json:"every,omitempty"`
+	// Tolerance simplifies track with Douglas-Peucker, in meters
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Fields selects io elements by name, all io elements are returned when empty
+	Fields []string `json:"fields,omitempty"`
+	Format string   `json:"format,omitempty"`
+}
+
+func (r *Request) Validate() error {
+	switch {
+	case r.IMEI == "":
+		return ErrMissingIMEI
+	case !r.To.After(r.From):
+		return ErrInvalidRange
+	case r.Every < 0 || r.Tolerance < 0:
+		return ErrInvalidSampling
+	case r.Format != "" && r.Format != FormatJSON && r.Format != FormatProtobuf:
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+type Service struct {
+	store     Store
+	log       *zap.Logger
+	maxPoints int
+	timeout   time.Duration
+}
+
+func NewService(store Store, logger *zap.Logger) *Service {
+	return &Service{
+		store:     store,
+		log:       logger,
+		maxPoints: DefaultMaxPoints,
+		timeout:   DefaultQueryTimeout,
+	}
+}
+
+// Query loads track of a device and applies downsampling and io field selection
+func (s *Service) Query(ctx context.Context, req *Request) ([]*pb.AVLData, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	// one extra point tells a track at the limit from a truncated one
+	points, err := s.store.LoadTrack(ctx, req.IMEI, req.From, req.To, s.maxPoints+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) > s.maxPoints {
+		return nil, fmt.Errorf("%w: more than %d points", ErrTooManyPoints, s.maxPoints)
+	}
+	points = EveryNth(points, req.Every)
+	points = Simplify(points, req.Tolerance)
+	if len(req.Fields) > 0 {
+		points = selectFields(points, req.Fields)
+	}
+	return points, nil
+}
+
+func selectFields(points []*pb.AVLData, fields []string) []*pb.AVLData {
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+	for _, point := range points {
+		elements := point.IoElements[:0]
+		for _, element := range point.IoElements {
+			if selected[element.GetElementName()] {
+				elements = append(elements, element)
+			}
+		}
+		point.IoElements = elements
+	}
+	return points
+}
+
+// Encode marshals points in requested format and returns its content type
+func Encode(points []*pb.AVLData, format string) ([]byte, string, error) {
+	response := &trackingpb.AllPointsDataResponse{Points: points}
+	if format == FormatProtobuf {
+		data, err := proto.Marshal(response)
+		return data, "application/x-protobuf", err
+	}
+	data, err := protojson.Marshal(response)
+	return data, "application/json", err
+}
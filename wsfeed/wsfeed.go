@@ -0,0 +1,213 @@
+package wsfeed
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/live"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Path is the http path live feed is served on
+const Path = "/api/v1/live"
+
+const (
+	DefaultPingInterval = time.Second * 30
+	DefaultWriteTimeout = time.Second * 10
+)
+
+var (
+	ErrMissingToken       = errors.New("live feed token is required")
+	ErrInvalidBoundingBox = errors.New("bbox must be minLongitude,minLatitude,maxLongitude,maxLatitude")
+
+	errUnauthorized = errors.New("unauthorized")
+)
+
+// Options configures live feed
+type Options struct {
+	// Token authenticates browsers, sent as token query parameter or bearer token
+	Token string
+	// BufferSize is the number of points buffered for every client, points are dropped for slower clients
+	BufferSize   int
+	PingInterval time.Duration
+	WriteTimeout time.Duration
+}
+
+// Handler serves decoded points of hub to websocket clients as JSON text messages.
+// Clients select devices by repeated or comma separated imei query parameters and an
+// optional bbox=minLongitude,minLatitude,maxLongitude,maxLatitude, all points are sent without them
+type Handler struct {
+	hub  *live.Hub
+	opts Options
+	log  *zap.Logger
+}
+
+func NewHandler(hub *live.Hub, opts Options, log *zap.Logger) (*Handler, error) {
+	if opts.Token == "" {
+		return nil, ErrMissingToken
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = live.DefaultBufferSize
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = DefaultPingInterval
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	return &Handler{
+		hub:  hub,
+		opts: opts,
+		log:  log,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.authorized(req) {
+		writeHTTPError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	filter, err := ParseFilter(req)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	// browsers of other origins are accepted, the token authenticates them
+	server := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			h.serve(conn, filter)
+		},
+	}
+	server.ServeHTTP(w, req)
+}
+
+func (h *Handler) authorized(req *http.Request) bool {
+	token := req.URL.Query().Get("token")
+	if bearer, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); found {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.Token)) == 1
+}
+
+// ParseFilter returns filter of imei and bbox query parameters of req
+func ParseFilter(req *http.Request) (live.Filter, error) {
+	query := req.URL.Query()
+	var imeis []string
+	for _, value := range query["imei"] {
+		for _, imei := range strings.Split(value, ",") {
+			if imei = strings.TrimSpace(imei); imei != "" {
+				imeis = append(imeis, imei)
+			}
+		}
+	}
+	filters := []live.Filter{live.IMEIFilter(imeis...)}
+	if value := query.Get("bbox"); value != "" {
+		box, err := parseBoundingBox(value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, live.BoundingBoxFilter(box))
+	}
+	return live.AllFilters(filters...), nil
+}
+
+func parseBoundingBox(value string) (live.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return live.BoundingBox{}, ErrInvalidBoundingBox
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return live.BoundingBox{}, fmt.Errorf("%w:%v", ErrInvalidBoundingBox, err)
+		}
+		coords[i] = coord
+	}
+	box := live.BoundingBox{
+		MinLongitude: coords[0],
+		MinLatitude:  coords[1],
+		MaxLongitude: coords[2],
+		MaxLatitude:  coords[3],
+	}
+	if box.MinLongitude > box.MaxLongitude || box.MinLatitude > box.MaxLatitude {
+		return live.BoundingBox{}, ErrInvalidBoundingBox
+	}
+	return box, nil
+}
+
+// serve writes points and pings to conn until the client disconnects, a write fails or hub is closed
+func (h *Handler) serve(conn *websocket.Conn, filter live.Filter) {
+	defer conn.Close()
+	sub := h.hub.Subscribe(filter, h.opts.BufferSize)
+	defer func() {
+		sub.Close()
+		if dropped := sub.Dropped(); dropped > 0 {
+			h.log.Warn("live feed dropped points",
+				zap.Uint64("dropped", dropped),
+				zap.String("remote_addr", conn.Request().RemoteAddr),
+			)
+		}
+	}()
+	// client messages are ignored, reading detects disconnects and answers pings
+	disconnected := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(disconnected)
+	}()
+	ticker := time.NewTicker(h.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-disconnected:
+			return
+		case point, ok := <-sub.Points():
+			if !ok {
+				return
+			}
+			if err := h.writePoint(conn, point); err != nil {
+				h.log.Debug("write live point failed", zap.Error(err))
+				return
+			}
+		case <-ticker.C:
+			if err := h.write(conn, websocket.PingFrame, nil); err != nil {
+				h.log.Debug("write live ping failed", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+func (h *Handler) writePoint(conn *websocket.Conn, point *pb.AVLData) error {
+	data, err := protojson.Marshal(point)
+	if err != nil {
+		return err
+	}
+	return h.write(conn, websocket.TextFrame, data)
+}
+
+// write sends a frame of payloadType, only the serve loop writes so payload type is not shared
+func (h *Handler) write(conn *websocket.Conn, payloadType byte, data []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(h.opts.WriteTimeout)); err != nil {
+		return err
+	}
+	conn.PayloadType = payloadType
+	_, err := conn.Write(data)
+	return err
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,90 @@
+package wsfeed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/irisco88/protos/gen/device/v1"
+	"github.com/irisco88/teltonika-device/live"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gotest.tools/v3/assert"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T, hub *live.Hub) *httptest.Server {
+	handler, err := NewHandler(hub, Options{Token: testToken, PingInterval: time.Millisecond * 10}, zap.NewNop())
+	assert.NilError(t, err)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func point(imei string, longitude, latitude float64) *pb.AVLData {
+	return &pb.AVLData{
+		Imei: imei,
+		Gps:  &pb.GPS{Longitude: longitude, Latitude: latitude},
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler(live.NewHub(), Options{}, zap.NewNop())
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestHandler_Auth(t *testing.T) {
+	server := newTestServer(t, live.NewHub())
+	tests := map[string]struct {
+		query string
+		code  int
+	}{
+		"missing token": {query: "", code: http.StatusUnauthorized},
+		"wrong token":   {query: "?token=wrong", code: http.StatusUnauthorized},
+		"invalid bbox":  {query: "?token=" + testToken + "&bbox=51,35", code: http.StatusBadRequest},
+		"not websocket": {query: "?token=" + testToken, code: http.StatusBadRequest},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + Path + test.query)
+			assert.NilError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, resp.StatusCode, test.code)
+		})
+	}
+}
+
+func TestHandler_Feed(t *testing.T) {
+	hub := live.NewHub()
+	server := newTestServer(t, hub)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + Path +
+		"?token=" + testToken + "&imei=356307042441013,352094087982671&bbox=51,35,52,36"
+	conn, err := websocket.Dial(wsURL, "", server.URL)
+	assert.NilError(t, err)
+	defer conn.Close()
+	for i := 0; i < 100 && hub.Subscribers() == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	assert.NilError(t, hub.SaveAvlPoints(context.Background(), []*pb.AVLData{
+		point("356478954125694", 51.4, 35.7),
+		point("356307042441013", 53.1, 35.7),
+		point("352094087982671", 51.4, 35.7),
+	}))
+	// pings are sent between points and answered by the client
+	time.Sleep(time.Millisecond * 30)
+	var msg string
+	assert.NilError(t, websocket.Message.Receive(conn, &msg))
+	received := &pb.AVLData{}
+	assert.NilError(t, protojson.Unmarshal([]byte(msg), received))
+	assert.Equal(t, received.GetImei(), "352094087982671")
+
+	// closing hub closes feeds
+	assert.NilError(t, hub.Close())
+	assert.Assert(t, websocket.Message.Receive(conn, &msg) != nil)
+}